@@ -2,6 +2,7 @@ package messagehandler
 
 import (
 	"elev/Network/messages"
+	"elev/Network/network/bcast"
 	"elev/config"
 	"elev/elevator"
 	"errors"
@@ -17,6 +18,7 @@ const (
 	HALL_LIGHT_UPDATE        MessageIDType = 1
 	CONNECTION_REQ           MessageIDType = 2
 	HALL_ASSIGNMENT_COMPLETE MessageIDType = 3
+	DESTINATION_CALL         MessageIDType = 4 // identifies destination calls, never acked
 )
 
 type NetworkEvent int
@@ -24,6 +26,7 @@ type NetworkEvent int
 const (
 	NodeConnectDisconnect NetworkEvent = iota
 	NodeHasLostConnection
+	NodeHealthChanged // a car became healthy or unhealthy, and should be given or relieved of hall requests
 )
 
 type ElevStateUpdate struct {
@@ -35,7 +38,7 @@ type ElevStateUpdate struct {
 func GenerateMessageID(partition MessageIDType) (uint64, error) {
 	offset := uint64(partition)
 
-	if offset > uint64(HALL_ASSIGNMENT_COMPLETE) {
+	if offset > uint64(DESTINATION_CALL) {
 		return 0, errors.New("invalid messageIDType")
 	}
 
@@ -74,6 +77,9 @@ func IncomingAckDistributor(ackRx <-chan messages.Ack,
 // you can requests to know the states by sending a string on  commandCh
 // commands are "getActiveElevStates", "getAllKnownNodes", "startConnectionTimeoutDetection"
 // known nodes includes both nodes that are considered active (you have recent contact) and "dead" nodes - previous contact have been made
+// nodes that are out of normal service, e.g. in maintenance, are known but never active
+// nodes that speak an incompatible protocol are reported, and are known but never active. Binary packets are sent
+// with the newest protocol version all compatible nodes speak, so older nodes keep working during a rolling upgrade
 func NodeElevStateServer(myID int,
 	commandRx <-chan string,
 	elevStateUpdateTx chan<- ElevStateUpdate,
@@ -91,11 +97,16 @@ func NodeElevStateServer(myID int,
 	lastSeen := make(map[int]time.Time)
 	knownNodes := make(map[int]elevator.ElevatorState)
 
+	protocols := make(map[int]messages.ProtocolInfo)
+	incompatible := make(map[int]bool)
+
 	lastActiveNodes := make(map[int]elevator.ElevatorState)
 	for {
 		select {
 		case <-peerTimeoutTicker.C:
-			activeNodes := findActiveNodes(knownNodes, lastSeen)
+			// the send version goes back up when the older nodes have left
+			negotiateSendVersion(protocols, incompatible, lastSeen)
+			activeNodes := findActiveNodes(knownNodes, lastSeen, incompatible)
 
 			// if the number of active nodes change, generate an event
 			if len(lastActiveNodes) != len(activeNodes) {
@@ -120,15 +131,39 @@ func NodeElevStateServer(myID int,
 					connectionTimeoutTimer.Reset(config.NODE_CONNECTION_TIMEOUT)
 				}
 
+				known, ok := knownNodes[id]
+				if ok && known.ServiceMode != elevState.ElevState.ServiceMode {
+					fmt.Printf("Node %d is now in %s service\n", id, elevState.ElevState.ServiceMode.String())
+				}
 				knownNodes[id] = elevState.ElevState
 				lastSeen[id] = time.Now()
+
+				protocol := messages.PeerProtocol(elevState)
+				if last, ok := protocols[id]; !ok || last != protocol {
+					protocols[id] = protocol
+					if err := messages.CheckCompatible(protocol); err != nil {
+						fmt.Printf("Node %d is incompatible and kept out of hall assignment, it %v\n", id, err)
+						incompatible[id] = true
+					} else {
+						fmt.Printf("Node %d speaks protocol version %d, capabilities: %v\n", id, protocol.Version, protocol.Capabilities)
+						delete(incompatible, id)
+					}
+					negotiateSendVersion(protocols, incompatible, lastSeen)
+				}
+
+				if ok && known.Health.Healthy() != elevState.ElevState.Health.Healthy() {
+					fmt.Printf("Node %d is now %s\n", id, elevState.ElevState.Health.String())
+					if nodeIsConnected {
+						networkEventTx <- NodeHealthChanged
+					}
+				}
 			}
 
 		case command := <-commandRx:
 
 			switch command {
 			case "getActiveElevStates":
-				activeNodes := findActiveNodes(knownNodes, lastSeen)
+				activeNodes := findActiveNodes(knownNodes, lastSeen, incompatible)
 
 				elevStateUpdateTx <- makeActiveElevStatesUpdateMessage(activeNodes)
 
@@ -152,12 +187,26 @@ func makeAllElevStatesUpdateMessage(elevStates map[int]elevator.ElevatorState) E
 	return ElevStateUpdate{NodeElevStatesMap: elevStates, OnlyActiveNodes: false}
 }
 
-func findActiveNodes(knownNodes map[int]elevator.ElevatorState, lastSeen map[int]time.Time) map[int]elevator.ElevatorState {
+func findActiveNodes(knownNodes map[int]elevator.ElevatorState, lastSeen map[int]time.Time, incompatible map[int]bool) map[int]elevator.ElevatorState {
 	activeNodes := make(map[int]elevator.ElevatorState)
 	for id, t := range lastSeen {
-		if time.Since(t) < config.NODE_CONNECTION_TIMEOUT {
+		if time.Since(t) < config.NODE_CONNECTION_TIMEOUT && knownNodes[id].ServiceMode == elevator.NormalService && !incompatible[id] {
 			activeNodes[id] = knownNodes[id]
 		}
 	}
 	return activeNodes
 }
+
+// negotiateSendVersion sends binary packets with the newest protocol version all compatible nodes heard from recently speak
+func negotiateSendVersion(protocols map[int]messages.ProtocolInfo, incompatible map[int]bool, lastSeen map[int]time.Time) {
+	version := bcast.PROTOCOL_VERSION
+	for id, protocol := range protocols {
+		if !incompatible[id] && time.Since(lastSeen[id]) < config.NODE_CONNECTION_TIMEOUT && protocol.Version < int(version) {
+			version = byte(protocol.Version)
+		}
+	}
+	if version != bcast.SendVersion() {
+		fmt.Printf("Sending with protocol version %d\n", version)
+		bcast.SetSendVersion(version)
+	}
+}
@@ -0,0 +1,58 @@
+package messagehandler
+
+import (
+	"elev/Network/messages"
+	"elev/Network/network/bcast"
+	"elev/config"
+	"elev/elevator"
+	"testing"
+	"time"
+)
+
+func TestFindActiveNodes(t *testing.T) {
+	recent, stale := time.Now(), time.Now().Add(-2*config.NODE_CONNECTION_TIMEOUT)
+	knownNodes := map[int]elevator.ElevatorState{
+		1: {Floor: 1},
+		2: {Floor: 2},
+		3: {Floor: 3},
+		4: {Floor: 0, ServiceMode: elevator.MaintenanceService},
+	}
+	lastSeen := map[int]time.Time{1: recent, 2: stale, 3: recent, 4: recent}
+	incompatible := map[int]bool{3: true}
+
+	active := findActiveNodes(knownNodes, lastSeen, incompatible)
+	// 2 has not been heard from, 3 is incompatible and 4 is out of service
+	if len(active) != 1 || active[1] != knownNodes[1] {
+		t.Errorf("got active nodes %v, want only 1", active)
+	}
+}
+
+func TestNegotiateSendVersion(t *testing.T) {
+	defer bcast.SetSendVersion(bcast.PROTOCOL_VERSION)
+	recent, stale := time.Now(), time.Now().Add(-2*config.NODE_CONNECTION_TIMEOUT)
+	own := messages.OwnProtocol()
+	older := messages.ProtocolInfo{Version: int(bcast.MIN_PROTOCOL_VERSION), MinVersion: int(bcast.MIN_PROTOCOL_VERSION)}
+
+	steps := []struct {
+		name         string
+		protocols    map[int]messages.ProtocolInfo
+		incompatible map[int]bool
+		lastSeen     map[int]time.Time
+		want         byte
+	}{
+		{"only nodes of our version", map[int]messages.ProtocolInfo{1: own, 2: own}, nil,
+			map[int]time.Time{1: recent, 2: recent}, bcast.PROTOCOL_VERSION},
+		{"lowered for an older node", map[int]messages.ProtocolInfo{1: own, 2: older}, nil,
+			map[int]time.Time{1: recent, 2: recent}, bcast.MIN_PROTOCOL_VERSION},
+		{"raised again when the older node is gone", map[int]messages.ProtocolInfo{1: own, 2: older}, nil,
+			map[int]time.Time{1: recent, 2: stale}, bcast.PROTOCOL_VERSION},
+		{"not lowered for an incompatible node", map[int]messages.ProtocolInfo{1: own, 2: older}, map[int]bool{2: true},
+			map[int]time.Time{1: recent, 2: recent}, bcast.PROTOCOL_VERSION},
+	}
+	for _, step := range steps {
+		negotiateSendVersion(step.protocols, step.incompatible, step.lastSeen)
+		if got := bcast.SendVersion(); got != step.want {
+			t.Errorf("%s: sending with version %d, want %d", step.name, got, step.want)
+		}
+	}
+}
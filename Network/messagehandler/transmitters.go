@@ -93,6 +93,42 @@ func GlobalHallRequestsTransmitter(transmitEnableCh <-chan bool, GlobalHallReque
 	}
 }
 
+// broadcasts the fire recall state with an interval, enable or disable by sending a bool in transmitEnableCh
+func RecallCommandTransmitter(transmitEnableCh <-chan bool, RecallCommandTx chan<- messages.RecallCommand, commandsForBroadcastCh <-chan messages.RecallCommand) {
+	enable := false
+	var recallCommand messages.RecallCommand
+
+	for {
+		select {
+
+		case enable = <-transmitEnableCh:
+		case recallCommand = <-commandsForBroadcastCh:
+		case <-time.After(config.MASTER_TRANSMIT_INTERVAL):
+			if enable {
+				RecallCommandTx <- recallCommand
+			}
+		}
+	}
+}
+
+// broadcasts the destination calls with an interval, enable or disable by sending a bool in transmitEnableCh
+func DestinationAssignmentsTransmitter(transmitEnableCh <-chan bool, DestinationAssignmentsTx chan<- messages.DestinationAssignments, assignmentsForBroadcastCh <-chan messages.DestinationAssignments) {
+	enable := false
+	var destinationAssignments messages.DestinationAssignments
+
+	for {
+		select {
+
+		case enable = <-transmitEnableCh:
+		case destinationAssignments = <-assignmentsForBroadcastCh:
+		case <-time.After(config.MASTER_TRANSMIT_INTERVAL):
+			if enable {
+				DestinationAssignmentsTx <- destinationAssignments
+			}
+		}
+	}
+}
+
 // transmits hall assignments complete
 func HallAssignmentCompleteTransmitter(HallAssignmentCompleteTx chan<- messages.HallAssignmentComplete,
 	OutgoingHallAssignmentComplete <-chan messages.HallAssignmentComplete,
@@ -36,6 +36,7 @@ type GlobalHallRequest struct {
 type NodeElevState struct {
 	NodeID    int
 	ElevState elevator.ElevatorState
+	Protocol  ProtocolInfo `bcast:"since=2"` // the protocol the node speaks, zero for nodes from before protocol version 2
 }
 
 // Broadcast when you are in state disconnected. used to create a connection with other node
@@ -49,7 +50,10 @@ type ConnectionReq struct {
 type NewHallAssignments struct {
 	NodeID         int
 	HallAssignment [config.NUM_FLOORS][2]bool
-	MessageID      uint64
+	HomeFloor      int // the floor to park at when idle
+	// destinations of the passengers to pick up at [floor][up/down], added as cab calls on pickup
+	Destinations [config.NUM_FLOORS][2][config.NUM_FLOORS]bool
+	MessageID    uint64
 }
 
 // When a slave gets a new hall button request, it broadcasts it to master in the form of a new hall request
@@ -58,9 +62,47 @@ type NewHallRequest struct {
 	HallButton elevator.ButtonType
 }
 
+// In destination dispatch, a passenger enters the destination at the floor instead of pressing up or down.
+// The node with the panel broadcasts it to master in the form of a new destination request
+type NewDestinationRequest struct {
+	CallID      uint64
+	PanelNodeID int // the node whose panel the call was entered at
+	Origin      int
+	Destination int
+}
+
+// A destination call known to master, and the car the passenger has been told to take
+type DestinationCall struct {
+	CallID      uint64
+	PanelNodeID int
+	Origin      int
+	Destination int
+	Car         int // -1 until the call is assigned
+}
+
+// the hall button the destination call is picked up with
+func (call DestinationCall) HallButton() elevator.ButtonType {
+	if call.Destination > call.Origin {
+		return elevator.ButtonHallUp
+	}
+	return elevator.ButtonHallDown
+}
+
+// Message with the destination calls waiting to be picked up. Broadcast by master at a fixed interval,
+// the nodes tell the passengers at their panel which car to take
+type DestinationAssignments struct {
+	Calls []DestinationCall
+}
+
 // When a slave finishes an assigned hall order, it sends this message
 type HallAssignmentComplete struct {
 	Floor      int
 	HallButton elevator.ButtonType
 	MessageID  uint64
 }
+
+// Fire recall state of the system. Broadcast by master at a fixed interval, nodes act when it changes
+type RecallCommand struct {
+	Active bool
+	Floor  int // the floor all cars are recalled to
+}
@@ -0,0 +1,89 @@
+package messages
+
+import (
+	"elev/Network/network/bcast"
+	"elev/config"
+	"fmt"
+	"strings"
+)
+
+// Capabilities are the optional features a node supports, advertised in its heartbeat
+type Capabilities uint64
+
+const (
+	CapBinaryWire          Capabilities = 1 << iota // decodes the binary wire format
+	CapDestinationDispatch                          // takes part in destination dispatch
+	CapHealthFlags                                  // reports the health of its car in its elevator state
+)
+
+var capabilityNames = []string{"binary", "destination", "health"}
+
+func (caps Capabilities) String() string {
+	var names []string
+	for i, name := range capabilityNames {
+		if caps&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// ProtocolInfo is the protocol a node speaks, advertised in its heartbeat
+type ProtocolInfo struct {
+	Version      int // the newest protocol version the node speaks
+	MinVersion   int // the oldest protocol version the node still speaks
+	Capabilities Capabilities
+}
+
+// legacyProtocol is the protocol of nodes from before the heartbeat carried one
+var legacyProtocol = ProtocolInfo{Version: 1, MinVersion: 1}
+
+// OwnProtocol returns the protocol this node speaks
+func OwnProtocol() ProtocolInfo {
+	return ProtocolInfo{
+		Version:      int(bcast.PROTOCOL_VERSION),
+		MinVersion:   int(bcast.MIN_PROTOCOL_VERSION),
+		Capabilities: CapBinaryWire | CapDestinationDispatch | CapHealthFlags,
+	}
+}
+
+// requiredCapabilities returns the capabilities a peer needs to work with this node as it is configured
+func requiredCapabilities() Capabilities {
+	var required Capabilities
+	if config.WIRE_FORMAT == bcast.BinaryFormatName {
+		required |= CapBinaryWire
+	}
+	if config.DESTINATION_DISPATCH {
+		required |= CapDestinationDispatch
+	}
+	return required
+}
+
+// PeerProtocol returns the protocol of the node that sent the heartbeat
+func PeerProtocol(heartbeat NodeElevState) ProtocolInfo {
+	if heartbeat.Protocol.Version == 0 {
+		return legacyProtocol
+	}
+	return heartbeat.Protocol
+}
+
+// CheckCompatible returns an error if the peer cannot work with this node: if they have no protocol version
+// in common, or the peer lacks a capability this node is configured to use. Legacy peers advertise no capabilities,
+// so only their version is checked.
+func CheckCompatible(peer ProtocolInfo) error {
+	own := OwnProtocol()
+	if peer.Version < own.MinVersion || peer.MinVersion > own.Version {
+		return fmt.Errorf("speaks protocol versions %d to %d, we speak %d to %d",
+			peer.MinVersion, peer.Version, own.MinVersion, own.Version)
+	}
+	if peer == legacyProtocol {
+		return nil
+	}
+	if missing := requiredCapabilities() &^ peer.Capabilities; missing != 0 {
+		return fmt.Errorf("lacks the capabilities: %v", missing)
+	}
+	return nil
+}
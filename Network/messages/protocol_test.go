@@ -0,0 +1,61 @@
+package messages
+
+import (
+	"elev/Network/network/bcast"
+	"testing"
+)
+
+func TestCheckCompatible(t *testing.T) {
+	own := OwnProtocol()
+	tests := []struct {
+		name       string
+		peer       ProtocolInfo
+		compatible bool
+	}{
+		{"the same protocol", own, true},
+		{"a legacy node", legacyProtocol, true},
+		{"a newer node that still speaks our version",
+			ProtocolInfo{Version: own.Version + 1, MinVersion: own.Version, Capabilities: own.Capabilities}, true},
+		{"a newer node that no longer speaks our versions",
+			ProtocolInfo{Version: own.Version + 2, MinVersion: own.Version + 1, Capabilities: own.Capabilities}, false},
+		{"an older node we no longer speak to", ProtocolInfo{Version: own.MinVersion - 1, MinVersion: own.MinVersion - 1}, false},
+		{"a node lacking a capability we use",
+			ProtocolInfo{Version: own.Version, MinVersion: own.MinVersion, Capabilities: own.Capabilities &^ requiredCapabilities()},
+			requiredCapabilities() == 0},
+		{"a node lacking a capability we do not use",
+			ProtocolInfo{Version: own.Version, MinVersion: own.MinVersion, Capabilities: requiredCapabilities()}, true},
+	}
+	for _, test := range tests {
+		if err := CheckCompatible(test.peer); (err == nil) != test.compatible {
+			t.Errorf("%s: got %v", test.name, err)
+		}
+	}
+}
+
+func TestPeerProtocol(t *testing.T) {
+	if got := PeerProtocol(NodeElevState{}); got != legacyProtocol {
+		t.Errorf("a heartbeat without a protocol: got %+v, want %+v", got, legacyProtocol)
+	}
+	if got := PeerProtocol(NodeElevState{Protocol: OwnProtocol()}); got != OwnProtocol() {
+		t.Errorf("got %+v, want %+v", got, OwnProtocol())
+	}
+	if own := OwnProtocol(); own.Version != int(bcast.PROTOCOL_VERSION) || own.MinVersion != int(bcast.MIN_PROTOCOL_VERSION) {
+		t.Errorf("own protocol %+v does not match the wire format", own)
+	}
+}
+
+func TestCapabilitiesString(t *testing.T) {
+	tests := []struct {
+		caps Capabilities
+		want string
+	}{
+		{0, "none"},
+		{CapBinaryWire, "binary"},
+		{CapBinaryWire | CapHealthFlags, "binary, health"},
+	}
+	for _, test := range tests {
+		if got := test.caps.String(); got != test.want {
+			t.Errorf("%d: got %q, want %q", test.caps, got, test.want)
+		}
+	}
+}
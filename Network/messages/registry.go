@@ -0,0 +1,19 @@
+package messages
+
+import "elev/Network/network/bcast"
+
+// The type ids of the messages in the binary wire format. All nodes must agree on them,
+// so an id is never changed or reused, new messages get new ids.
+func init() {
+	bcast.RegisterType(1, Ack{})
+	bcast.RegisterType(2, CabRequestInfo{})
+	bcast.RegisterType(3, GlobalHallRequest{})
+	bcast.RegisterType(4, NodeElevState{})
+	bcast.RegisterType(5, ConnectionReq{})
+	bcast.RegisterType(6, NewHallAssignments{})
+	bcast.RegisterType(7, NewHallRequest{})
+	bcast.RegisterType(8, NewDestinationRequest{})
+	bcast.RegisterType(9, DestinationAssignments{})
+	bcast.RegisterType(10, HallAssignmentComplete{})
+	bcast.RegisterType(11, RecallCommand{})
+}
@@ -0,0 +1,154 @@
+package bcast
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Signed packets are the packet of the wire format wrapped in a header and a MAC:
+//
+//	AUTH_MAGIC | timestamp (8 bytes) | nonce (8 bytes) | packet | HMAC-SHA256 of all before it (32 bytes)
+//
+// A packet is only accepted if its timestamp is within the replay window of our clock and its nonce has not been
+// seen within the window, so the clocks of the nodes must agree to well within the window.
+const (
+	AUTH_MAGIC       byte = 0xA5 // neither '{' nor a protocol version
+	authHeaderSize        = 1 + 8 + 8
+	authMACSize           = sha256.Size
+	minClusterKeyLen      = 16
+)
+
+var (
+	errUnsigned = errors.New("unsigned packet")
+	errBadMAC   = errors.New("packet with a bad MAC")
+	errStale    = errors.New("packet outside the replay window")
+	errReplayed = errors.New("replayed packet")
+)
+
+// AuthStats counts the packets checked by an Authenticator
+type AuthStats struct {
+	Accepted uint64
+	Unsigned uint64 // not signed at all, from a node without the key or another application
+	BadMAC   uint64 // signed with another key, or altered on the way
+	Stale    uint64 // too old or from the future, or the clocks of the nodes disagree
+	Replayed uint64 // a copy of a packet that was already accepted
+}
+
+// Rejected returns the number of packets that failed authentication
+func (stats AuthStats) Rejected() uint64 {
+	return stats.Unsigned + stats.BadMAC + stats.Stale + stats.Replayed
+}
+
+func (stats AuthStats) String() string {
+	return fmt.Sprintf("%d accepted, %d rejected (%d unsigned, %d bad MAC, %d stale, %d replayed)",
+		stats.Accepted, stats.Rejected(), stats.Unsigned, stats.BadMAC, stats.Stale, stats.Replayed)
+}
+
+// Authenticator signs packets and checks the signed packets with a key shared by all nodes of the cluster.
+// It is safe to use from the Broadcaster and the Receiver at once.
+type Authenticator struct {
+	key    []byte
+	window time.Duration
+
+	mu        sync.Mutex
+	seen      map[uint64]time.Time // the nonces of the packets accepted within the window, with their timestamps
+	lastPrune time.Time
+	stats     AuthStats
+}
+
+func NewAuthenticator(key []byte, window time.Duration) *Authenticator {
+	return &Authenticator{key: key, window: window, seen: make(map[uint64]time.Time)}
+}
+
+// LoadAuthenticator makes an Authenticator with the key in the file at path. Whitespace around the key is ignored.
+func LoadAuthenticator(path string, window time.Duration) (*Authenticator, error) {
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key = bytes.TrimSpace(key)
+	if len(key) < minClusterKeyLen {
+		return nil, fmt.Errorf("the cluster key in %s is shorter than %d bytes", path, minClusterKeyLen)
+	}
+	return NewAuthenticator(key, window), nil
+}
+
+// Stats returns the number of packets accepted and rejected so far
+func (auth *Authenticator) Stats() AuthStats {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	return auth.stats
+}
+
+// sign wraps a packet in the header and the MAC
+func (auth *Authenticator) sign(packet []byte) []byte {
+	signed := make([]byte, 0, authHeaderSize+len(packet)+authMACSize)
+	signed = append(signed, AUTH_MAGIC)
+	signed = binary.BigEndian.AppendUint64(signed, uint64(time.Now().UnixNano()))
+	var nonce [8]byte
+	rand.Read(nonce[:])
+	signed = append(signed, nonce[:]...)
+	signed = append(signed, packet...)
+
+	mac := hmac.New(sha256.New, auth.key)
+	mac.Write(signed)
+	return mac.Sum(signed)
+}
+
+// verify checks a signed packet and returns the packet inside it
+func (auth *Authenticator) verify(signed []byte) ([]byte, error) {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+
+	if len(signed) < authHeaderSize+authMACSize || signed[0] != AUTH_MAGIC {
+		auth.stats.Unsigned++
+		return nil, errUnsigned
+	}
+
+	body := signed[:len(signed)-authMACSize]
+	mac := hmac.New(sha256.New, auth.key)
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), signed[len(body):]) {
+		auth.stats.BadMAC++
+		return nil, errBadMAC
+	}
+
+	now := time.Now()
+	timestamp := time.Unix(0, int64(binary.BigEndian.Uint64(signed[1:9])))
+	if timestamp.Before(now.Add(-auth.window)) || timestamp.After(now.Add(auth.window)) {
+		auth.stats.Stale++
+		return nil, errStale
+	}
+
+	nonce := binary.BigEndian.Uint64(signed[9:17])
+	if _, ok := auth.seen[nonce]; ok {
+		auth.stats.Replayed++
+		return nil, errReplayed
+	}
+	auth.seen[nonce] = timestamp
+	auth.pruneSeen(now)
+
+	auth.stats.Accepted++
+	return body[authHeaderSize:], nil
+}
+
+// pruneSeen forgets the nonces of packets that would be stale by now, at most once per window
+func (auth *Authenticator) pruneSeen(now time.Time) {
+	if now.Sub(auth.lastPrune) < auth.window {
+		return
+	}
+	auth.lastPrune = now
+	for nonce, timestamp := range auth.seen {
+		if timestamp.Before(now.Add(-auth.window)) {
+			delete(auth.seen, nonce)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package bcast
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testWindow = 2 * time.Second
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+// signAt signs a packet like Authenticator.sign, with a chosen timestamp and nonce
+func signAt(key []byte, timestamp time.Time, nonce uint64, packet []byte) []byte {
+	signed := []byte{AUTH_MAGIC}
+	signed = binary.BigEndian.AppendUint64(signed, uint64(timestamp.UnixNano()))
+	signed = binary.BigEndian.AppendUint64(signed, nonce)
+	signed = append(signed, packet...)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(signed)
+	return mac.Sum(signed)
+}
+
+func TestVerify(t *testing.T) {
+	packet := []byte("packet of the wire format")
+	now := time.Now()
+
+	tampered := signAt(testKey, now, 2, packet)
+	tampered[authHeaderSize] ^= 1
+
+	tests := []struct {
+		name    string
+		signed  []byte
+		wantErr error
+	}{
+		{"good packet", signAt(testKey, now, 1, packet), nil},
+		{"altered packet", tampered, errBadMAC},
+		{"wrong key", signAt([]byte("another key of the right length"), now, 3, packet), errBadMAC},
+		{"stale timestamp", signAt(testKey, now.Add(-2*testWindow), 4, packet), errStale},
+		{"future timestamp", signAt(testKey, now.Add(2*testWindow), 5, packet), errStale},
+		{"within the window", signAt(testKey, now.Add(-testWindow/2), 6, packet), nil},
+		{"replayed nonce", signAt(testKey, now, 1, packet), errReplayed},
+		{"unsigned packet", []byte(`{"TypeId":"messages.Ack"}`), errUnsigned},
+		{"too short to be signed", []byte{AUTH_MAGIC, 0, 0}, errUnsigned},
+	}
+
+	auth := NewAuthenticator(testKey, testWindow)
+	for _, test := range tests {
+		got, err := auth.verify(test.signed)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.wantErr)
+			continue
+		}
+		if err == nil && !bytes.Equal(got, packet) {
+			t.Errorf("%s: got packet %q, want %q", test.name, got, packet)
+		}
+	}
+
+	want := AuthStats{Accepted: 2, Unsigned: 2, BadMAC: 2, Stale: 2, Replayed: 1}
+	if stats := auth.Stats(); stats != want {
+		t.Errorf("got stats %v, want %v", stats, want)
+	}
+	if rejected := auth.Stats().Rejected(); rejected != 7 {
+		t.Errorf("got %d rejected, want 7", rejected)
+	}
+}
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	sender := NewAuthenticator(testKey, testWindow)
+	receiver := NewAuthenticator(testKey, testWindow)
+	for _, packet := range [][]byte{{}, []byte("a"), bytes.Repeat([]byte{0xff}, BUF_SIZE)} {
+		signed := sender.sign(packet)
+		if len(signed) != authHeaderSize+len(packet)+authMACSize {
+			t.Errorf("signed packet of %d bytes is %d bytes", len(packet), len(signed))
+		}
+		got, err := receiver.verify(signed)
+		if err != nil || !bytes.Equal(got, packet) {
+			t.Errorf("packet of %d bytes: got %x, %v", len(packet), got, err)
+		}
+	}
+
+	// every packet has its own nonce, so signing the same packet twice is not a replay
+	if _, err := receiver.verify(sender.sign([]byte("a"))); err != nil {
+		t.Errorf("the same packet signed again: %v", err)
+	}
+}
+
+func TestLoadAuthenticator(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, append(append([]byte("  "), testKey...), '\n'), 0600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := LoadAuthenticator(keyFile, testWindow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the whitespace around the key is not part of it
+	if _, err := auth.verify(signAt(testKey, time.Now(), 1, []byte("packet"))); err != nil {
+		t.Errorf("packet signed with the trimmed key: %v", err)
+	}
+
+	shortFile := filepath.Join(dir, "short")
+	if err := os.WriteFile(shortFile, []byte("short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAuthenticator(shortFile, testWindow); err == nil {
+		t.Error("a key shorter than the minimum was accepted")
+	}
+	if _, err := LoadAuthenticator(filepath.Join(dir, "missing"), testWindow); err == nil {
+		t.Error("a missing key file was accepted")
+	}
+}
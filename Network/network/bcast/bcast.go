@@ -2,78 +2,134 @@ package bcast
 
 import (
 	"elev/Network/network/conn"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net"
 	"reflect"
+	"time"
 )
 
 const BUF_SIZE = 1024
 
-// Broadcaster encodes received values from `chans` into type-tagged JSON, then broadcasts
-// it on the specified 'port'. It takes multiple channels as input.
-func Broadcaster(port int, chans ...interface{}) {
+const maxReported = 100
+
+// Options configures a Broadcaster or a Receiver
+type Options struct {
+	Format WireFormat     // the format the Broadcaster sends in, the Receiver decodes both
+	Auth   *Authenticator // if set, the packets sent are signed and the packets received must be signed
+}
+
+// Broadcaster encodes received values from `chans` in the wire format of 'opts', then sends
+// it to all nodes on the specified 'port' with the transport in config.TRANSPORT. Values that do not fit
+// in a datagram are sent in fragments. It takes multiple channels as input.
+func Broadcaster(port int, opts Options, chans ...interface{}) {
 	checkArgs(chans...)
-	typeNames := make([]string, len(chans))
-	selectCases := make([]reflect.SelectCase, len(typeNames))
+	if opts.Format == BinaryFormat {
+		checkRegistered(chans...)
+	}
+	selectCases := make([]reflect.SelectCase, len(chans))
 	for i, ch := range chans {
 		selectCases[i] = reflect.SelectCase{
 			Dir:  reflect.SelectRecv,
 			Chan: reflect.ValueOf(ch),
 		}
-		typeNames[i] = reflect.TypeOf(ch).Elem().String()
 	}
 
-	conn := conn.DialBroadcastUDP(port)
-	addr, _ := net.ResolveUDPAddr("udp4", fmt.Sprintf("255.255.255.255:%d", port))
+	// the fragments must fit in a datagram with the signature
+	maxFragmentSize := BUF_SIZE
+	if opts.Auth != nil {
+		maxFragmentSize -= authHeaderSize + authMACSize
+	}
+	fragmenter := newFragmenter()
+
+	conn, addrs := conn.DialTransport(port)
 	for {
-		chosen, value, _ := reflect.Select(selectCases)
-		jsonstr, _ := json.Marshal(value.Interface())
-		ttj, _ := json.Marshal(typeTaggedJSON{
-			TypeId: typeNames[chosen],
-			JSON:   jsonstr,
-		})
-		if len(ttj) > BUF_SIZE {
-			panic(fmt.Sprintf(
-				"Tried to send a message longer than the buffer size (length: %d, buffer size: %d)\n\t'%s'\n"+
-					"Either send smaller packets, or go to network/bcast/bcast.go and increase the buffer size",
-				len(ttj), BUF_SIZE, string(ttj)))
+		_, value, _ := reflect.Select(selectCases)
+		packet, err := encodePacket(opts.Format, value)
+		if err != nil {
+			fmt.Printf("bcast.Broadcaster(%d, ...): could not encode '%s': %v\n", port, value.Type().String(), err)
+			continue
+		}
+		fragments, err := fragmenter.split(packet, maxFragmentSize)
+		if err != nil {
+			fmt.Printf("bcast.Broadcaster(%d, ...): dropping '%s': %v\n", port, value.Type().String(), err)
+			continue
+		}
+		for _, fragment := range fragments {
+			if opts.Auth != nil {
+				fragment = opts.Auth.sign(fragment)
+			}
+			for _, addr := range addrs {
+				conn.WriteTo(fragment, addr)
+			}
 		}
-		conn.WriteTo(ttj, addr)
 
 	}
 }
 
-// Receiver matches type-tagged JSON received on 'port' to element types of 'chans', then
+// Receiver matches packets of either wire format received on 'port' to element types of 'chans', then
 // sends the decoded value on the corresponding channel. It takes multiple channels as input.
-func Receiver(port int, chans ...interface{}) {
+// With an Authenticator in 'opts', packets that fail authentication are counted and dropped.
+func Receiver(port int, opts Options, chans ...interface{}) {
 	checkArgs(chans...)
 	chansMap := make(map[string]interface{})
 	for _, ch := range chans {
 		chansMap[reflect.TypeOf(ch).Elem().String()] = ch
 	}
 
+	// packets that are dropped are reported once per protocol version, message type, type that failed to decode
+	// or authentication failure, as they usually come from a misconfigured node and keep coming
+	reported := make(map[string]bool)
+
+	reassembler := newReassembler()
+
 	var buf [BUF_SIZE]byte
-	conn := conn.DialBroadcastUDP(port)
+	conn := conn.ListenTransport(port)
 	for {
-		n, _, e := conn.ReadFrom(buf[0:])
+		n, sender, e := conn.ReadFrom(buf[0:])
 		if e != nil {
 			fmt.Printf("bcast.Receiver(%d, ...):ReadFrom() failed: \"%+v\"\n", port, e)
+			continue
 		}
 
-		var ttj typeTaggedJSON
-		json.Unmarshal(buf[0:n], &ttj)
-		ch, ok := chansMap[ttj.TypeId]
-		if !ok {
-			// fmt.Println("Received junk data")
+		if expired := reassembler.expire(time.Now()); expired > 0 && !reported[errReassemblyTimeout.Error()] {
+			reported[errReassemblyTimeout.Error()] = true
+			fmt.Printf("bcast.Receiver(%d, ...): dropping %d fragmented messages, %v\n", port, expired, errReassemblyTimeout)
+		}
+
+		packet := buf[0:n]
+		var err error
+		if opts.Auth != nil {
+			packet, err = opts.Auth.verify(packet)
+		}
+		if err == nil && isFragment(packet) {
+			packet, err = reassembler.add(sender.String(), packet, time.Now())
+			if err == nil && packet == nil {
+				// waiting for the rest of the fragments
+				continue
+			}
+		}
+		var ch interface{}
+		var v reflect.Value
+		if err == nil {
+			ch, v, err = decodePacket(packet, chansMap)
+		}
+		if err != nil {
+			key := err.Error()
+			var decodeErr *decodeError
+			if errors.As(err, &decodeErr) {
+				key = decodeErr.TypeName
+			}
+			// junk data could make up any number of keys
+			if !reported[key] && len(reported) < maxReported {
+				reported[key] = true
+				fmt.Printf("bcast.Receiver(%d, ...): dropping packets, %v\n", port, err)
+			}
 			continue
 		}
-		v := reflect.New(reflect.TypeOf(ch).Elem())
-		json.Unmarshal(ttj.JSON, v.Interface())
 		reflect.Select([]reflect.SelectCase{{
 			Dir:  reflect.SelectSend,
 			Chan: reflect.ValueOf(ch),
-			Send: reflect.Indirect(v),
+			Send: v,
 		}})
 	}
 }
@@ -125,6 +181,18 @@ func checkArgs(chans ...interface{}) {
 	}
 }
 
+// Checks that the element types of all channels have a type id for the binary wire format
+func checkRegistered(chans ...interface{}) {
+	for i, ch := range chans {
+		elemType := reflect.TypeOf(ch).Elem()
+		if _, ok := typeIDs[elemType]; !ok {
+			panic(fmt.Sprintf(
+				"Channel element type '%s' has no type id for the binary wire format, register it with bcast.RegisterType (arg# %d)",
+				elemType.String(), i+1))
+		}
+	}
+}
+
 // Recursively checks that `val` is encodable with JSON
 func checkTypeRecursive(val reflect.Type, offsets []int) {
 	switch val.Kind() {
@@ -0,0 +1,291 @@
+package bcast
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// The binary encoding has no field names or type information, both ends walk the type in the same order:
+//   - bools are one byte, except arrays of bools which are packed eight to a byte
+//   - integers are varints, signed ones zigzag encoded
+//   - floats are 4 or 8 bytes, little endian
+//   - strings and slices are their length as a varint followed by the bytes or elements
+//   - maps are their length followed by key, value pairs
+//   - pointers are a byte telling if they are nil, followed by the value they point to
+//   - structs are their exported fields in order, like JSON. A field added in a later protocol version is tagged
+//     `bcast:"since=N"`, and is left out of packets of older versions and zero when decoding them
+//   - types implementing encoding.BinaryMarshaler, such as time.Time, are their marshalled bytes as a string
+
+var (
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+var errShortPacket = errors.New("packet too short")
+
+// fieldSince returns the protocol version a struct field was added in
+func fieldSince(field reflect.StructField) byte {
+	var since byte
+	if _, err := fmt.Sscanf(field.Tag.Get("bcast"), "since=%d", &since); err != nil {
+		return MIN_PROTOCOL_VERSION
+	}
+	return since
+}
+
+func appendBinary(version byte, buf []byte, v reflect.Value) ([]byte, error) {
+	if v.Type().Implements(binaryMarshalerType) && reflect.PointerTo(v.Type()).Implements(binaryUnmarshalerType) {
+		data, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(data)))
+		return append(buf, data...), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(buf, v.Int()), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.AppendUvarint(buf, v.Uint()), nil
+
+	case reflect.Float32:
+		return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(v.Float()))), nil
+
+	case reflect.Float64:
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v.Float())), nil
+
+	case reflect.String:
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		return append(buf, v.String()...), nil
+
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Bool {
+			packed := make([]byte, (v.Len()+7)/8)
+			for i := 0; i < v.Len(); i++ {
+				if v.Index(i).Bool() {
+					packed[i/8] |= 1 << (i % 8)
+				}
+			}
+			return append(buf, packed...), nil
+		}
+		return appendElements(version, buf, v)
+
+	case reflect.Slice:
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		return appendElements(version, buf, v)
+
+	case reflect.Map:
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		var err error
+		iter := v.MapRange()
+		for iter.Next() {
+			if buf, err = appendBinary(version, buf, iter.Key()); err != nil {
+				return nil, err
+			}
+			if buf, err = appendBinary(version, buf, iter.Value()); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return append(buf, 0), nil
+		}
+		return appendBinary(version, append(buf, 1), v.Elem())
+
+	case reflect.Struct:
+		var err error
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() || fieldSince(v.Type().Field(i)) > version {
+				continue
+			}
+			if buf, err = appendBinary(version, buf, v.Field(i)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+
+	default:
+		return nil, fmt.Errorf("cannot encode '%s' in binary", v.Type().String())
+	}
+}
+
+func appendElements(version byte, buf []byte, v reflect.Value) ([]byte, error) {
+	var err error
+	for i := 0; i < v.Len(); i++ {
+		if buf, err = appendBinary(version, buf, v.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// readBinary decodes data into v, which must be settable. It returns the data after the value.
+func readBinary(version byte, data []byte, v reflect.Value) ([]byte, error) {
+	if v.Type().Implements(binaryMarshalerType) && reflect.PointerTo(v.Type()).Implements(binaryUnmarshalerType) {
+		raw, rest, err := readBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		return rest, v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(raw)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if len(data) < 1 {
+			return nil, errShortPacket
+		}
+		v.SetBool(data[0] != 0)
+		return data[1:], nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, errShortPacket
+		}
+		if v.OverflowInt(x) {
+			return nil, fmt.Errorf("%d overflows '%s'", x, v.Type().String())
+		}
+		v.SetInt(x)
+		return data[n:], nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		x, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errShortPacket
+		}
+		if v.OverflowUint(x) {
+			return nil, fmt.Errorf("%d overflows '%s'", x, v.Type().String())
+		}
+		v.SetUint(x)
+		return data[n:], nil
+
+	case reflect.Float32:
+		if len(data) < 4 {
+			return nil, errShortPacket
+		}
+		v.SetFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(data))))
+		return data[4:], nil
+
+	case reflect.Float64:
+		if len(data) < 8 {
+			return nil, errShortPacket
+		}
+		v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(data)))
+		return data[8:], nil
+
+	case reflect.String:
+		raw, rest, err := readBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		v.SetString(string(raw))
+		return rest, nil
+
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Bool {
+			size := (v.Len() + 7) / 8
+			if len(data) < size {
+				return nil, errShortPacket
+			}
+			for i := 0; i < v.Len(); i++ {
+				v.Index(i).SetBool(data[i/8]&(1<<(i%8)) != 0)
+			}
+			return data[size:], nil
+		}
+		return readElements(version, data, v)
+
+	case reflect.Slice:
+		n, rest, err := readLength(data)
+		if err != nil {
+			return nil, err
+		}
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+		return readElements(version, rest, v)
+
+	case reflect.Map:
+		n, rest, err := readLength(data)
+		if err != nil {
+			return nil, err
+		}
+		v.Set(reflect.MakeMapWithSize(v.Type(), n))
+		for i := 0; i < n; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			if rest, err = readBinary(version, rest, key); err != nil {
+				return nil, err
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if rest, err = readBinary(version, rest, elem); err != nil {
+				return nil, err
+			}
+			v.SetMapIndex(key, elem)
+		}
+		return rest, nil
+
+	case reflect.Ptr:
+		if len(data) < 1 {
+			return nil, errShortPacket
+		}
+		if data[0] == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return data[1:], nil
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		return readBinary(version, data[1:], v.Elem())
+
+	case reflect.Struct:
+		var err error
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() || fieldSince(v.Type().Field(i)) > version {
+				continue
+			}
+			if data, err = readBinary(version, data, v.Field(i)); err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+
+	default:
+		return nil, fmt.Errorf("cannot decode '%s' from binary", v.Type().String())
+	}
+}
+
+func readElements(version byte, data []byte, v reflect.Value) ([]byte, error) {
+	var err error
+	for i := 0; i < v.Len(); i++ {
+		if data, err = readBinary(version, data, v.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// readLength reads the length of a string, slice or map. Every element takes at least a byte,
+// so a length longer than the rest of the packet is corrupt.
+func readLength(data []byte) (int, []byte, error) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 || n > uint64(len(data)-size) {
+		return 0, nil, errShortPacket
+	}
+	return int(n), data[size:], nil
+}
+
+func readBytes(data []byte) ([]byte, []byte, error) {
+	n, rest, err := readLength(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rest[:n], rest[n:], nil
+}
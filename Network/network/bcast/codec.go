@@ -0,0 +1,184 @@
+package bcast
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+)
+
+// WireFormat is how the Broadcaster encodes the values it sends. The Receiver decodes both formats,
+// so a deployment can be moved from JSON to binary one node at a time.
+type WireFormat int
+
+const (
+	JSONFormat   WireFormat = iota // type-tagged JSON, the type name as a string and the value as base64 JSON
+	BinaryFormat                   // the protocol version, the numeric type id and the value in compact binary
+)
+
+const (
+	JSONFormatName   = "json"
+	BinaryFormatName = "binary"
+)
+
+// PROTOCOL_VERSION is the first byte of every binary packet. Bump it when the binary encoding of a message changes,
+// and tag the fields added with the new version. Packets from MIN_PROTOCOL_VERSION up are decoded, others are dropped.
+//   - 1: the first binary format
+//   - 2: the heartbeat carries the protocol version and capabilities of the node
+const (
+	PROTOCOL_VERSION     byte = 2
+	MIN_PROTOCOL_VERSION byte = 1
+)
+
+// the version binary packets are sent with, lowered to the version of the oldest peer during a rolling upgrade
+var sendVersion atomic.Uint32
+
+func init() {
+	sendVersion.Store(uint32(PROTOCOL_VERSION))
+}
+
+// SetSendVersion sets the protocol version of the binary packets sent from now on, within the supported versions
+func SetSendVersion(version byte) {
+	version = max(MIN_PROTOCOL_VERSION, min(version, PROTOCOL_VERSION))
+	sendVersion.Store(uint32(version))
+}
+
+// SendVersion returns the protocol version binary packets are sent with
+func SendVersion() byte {
+	return byte(sendVersion.Load())
+}
+
+func WireFormatFromName(name string) (WireFormat, error) {
+	switch name {
+	case JSONFormatName:
+		return JSONFormat, nil
+	case BinaryFormatName:
+		return BinaryFormat, nil
+	default:
+		return JSONFormat, fmt.Errorf("unknown wire format %q", name)
+	}
+}
+
+func (format WireFormat) String() string {
+	switch format {
+	case JSONFormat:
+		return JSONFormatName
+	case BinaryFormat:
+		return BinaryFormatName
+	default:
+		return fmt.Sprintf("unknown(%d)", int(format))
+	}
+}
+
+// the message types of binary packets, filled in by RegisterType
+var (
+	typeIDs   = make(map[reflect.Type]uint64)
+	typesByID = make(map[uint64]reflect.Type)
+)
+
+// RegisterType gives the type of value a numeric id in binary packets. All nodes must register the same ids,
+// and an id must never be reused for another type. Types are registered at init, before any Broadcaster is started.
+func RegisterType(id uint64, value interface{}) {
+	t := reflect.TypeOf(value)
+	if other, ok := typesByID[id]; ok {
+		panic(fmt.Sprintf("Type id %d is registered for both '%s' and '%s'", id, other.String(), t.String()))
+	}
+	if otherID, ok := typeIDs[t]; ok {
+		panic(fmt.Sprintf("Type '%s' is registered with both id %d and id %d", t.String(), otherID, id))
+	}
+	typeIDs[t] = id
+	typesByID[id] = t
+}
+
+// encodePacket encodes a value of one of the channel element types in the given format
+func encodePacket(format WireFormat, value reflect.Value) ([]byte, error) {
+	if format == BinaryFormat {
+		id, ok := typeIDs[value.Type()]
+		if !ok {
+			return nil, fmt.Errorf("type '%s' has no registered id", value.Type().String())
+		}
+		version := SendVersion()
+		packet := []byte{version}
+		packet = binary.AppendUvarint(packet, id)
+		return appendBinary(version, packet, value)
+	}
+
+	jsonstr, err := json.Marshal(value.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(typeTaggedJSON{
+		TypeId: value.Type().String(),
+		JSON:   jsonstr,
+	})
+}
+
+// decodeError is returned for packets of a known type that could not be decoded
+type decodeError struct {
+	TypeName string
+	Err      error
+}
+
+func (e *decodeError) Error() string {
+	return fmt.Sprintf("could not decode '%s': %v", e.TypeName, e.Err)
+}
+
+// errVersion is returned for binary packets of another protocol version
+type errVersion byte
+
+func (version errVersion) Error() string {
+	return fmt.Sprintf("protocol version %d, supported are %d to %d", byte(version), MIN_PROTOCOL_VERSION, PROTOCOL_VERSION)
+}
+
+// decodePacket decodes a packet of either format into a value of the element type of one of the channels,
+// which are keyed by the name of their element type. It returns the channel and the value.
+func decodePacket(packet []byte, chansMap map[string]interface{}) (interface{}, reflect.Value, error) {
+	if len(packet) == 0 {
+		return nil, reflect.Value{}, errors.New("empty packet")
+	}
+
+	// JSON packets are objects, binary packets never start with '{'
+	if packet[0] == '{' {
+		var ttj typeTaggedJSON
+		if err := json.Unmarshal(packet, &ttj); err != nil {
+			return nil, reflect.Value{}, err
+		}
+		ch, ok := chansMap[ttj.TypeId]
+		if !ok {
+			return nil, reflect.Value{}, fmt.Errorf("unknown message type '%s'", ttj.TypeId)
+		}
+		v := reflect.New(reflect.TypeOf(ch).Elem())
+		if err := json.Unmarshal(ttj.JSON, v.Interface()); err != nil {
+			return nil, reflect.Value{}, &decodeError{ttj.TypeId, err}
+		}
+		return ch, reflect.Indirect(v), nil
+	}
+
+	version := packet[0]
+	if version < MIN_PROTOCOL_VERSION || version > PROTOCOL_VERSION {
+		return nil, reflect.Value{}, errVersion(version)
+	}
+	id, n := binary.Uvarint(packet[1:])
+	if n <= 0 {
+		return nil, reflect.Value{}, errors.New("bad type id")
+	}
+	t, ok := typesByID[id]
+	if !ok {
+		return nil, reflect.Value{}, fmt.Errorf("unknown message type id %d", id)
+	}
+	ch, ok := chansMap[t.String()]
+	if !ok {
+		return nil, reflect.Value{}, fmt.Errorf("unknown message type '%s'", t.String())
+	}
+	v := reflect.New(t).Elem()
+	rest, err := readBinary(version, packet[1+n:], v)
+	if err != nil {
+		return nil, reflect.Value{}, &decodeError{t.String(), err}
+	}
+	if len(rest) != 0 {
+		return nil, reflect.Value{}, &decodeError{t.String(), fmt.Errorf("%d bytes left over", len(rest))}
+	}
+	return ch, v, nil
+}
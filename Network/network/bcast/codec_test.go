@@ -0,0 +1,256 @@
+package bcast_test
+
+import (
+	_ "elev/Network/messages" // registers the message types
+	"elev/Network/network/bcast"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// fill sets v to arbitrary non-zero values, with slices and maps of one to three elements
+func fill(v reflect.Value, rng *rand.Rand) {
+	if v.Type() == timeType {
+		// UTC without a monotonic reading, so it compares equal after a round trip
+		v.Set(reflect.ValueOf(time.Unix(rng.Int63n(1<<32), rng.Int63n(1e9)).UTC()))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(rng.Intn(2) == 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(rng.Int63n(200) - 100)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(rng.Uint64() >> (64 - v.Type().Bits()))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(rng.Float64())
+	case reflect.String:
+		v.SetString("value")
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fill(v.Index(i), rng)
+		}
+	case reflect.Slice:
+		n := 1 + rng.Intn(3)
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+		for i := 0; i < n; i++ {
+			fill(v.Index(i), rng)
+		}
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		for i := 0; i < 1+rng.Intn(3); i++ {
+			key, elem := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+			fill(key, rng)
+			fill(elem, rng)
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), rng)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i), rng)
+			}
+		}
+	}
+}
+
+// zeroAfter zeroes the struct fields of v added after version, as decoding a packet of that version leaves them
+func zeroAfter(v reflect.Value, version byte) {
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			zeroAfter(v.Index(i), version)
+		}
+	case reflect.Ptr:
+		if !v.IsNil() {
+			zeroAfter(v.Elem(), version)
+		}
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if bcast.FieldSince(field) > version {
+				v.Field(i).Set(reflect.Zero(field.Type))
+			} else {
+				zeroAfter(v.Field(i), version)
+			}
+		}
+	}
+}
+
+// registeredChans returns a channel for every registered message type, keyed by the name of its type like the Receiver does
+func registeredChans() map[string]interface{} {
+	chansMap := make(map[string]interface{})
+	for _, t := range bcast.RegisteredTypes() {
+		chansMap[t.String()] = reflect.MakeChan(reflect.ChanOf(reflect.BothDir, t), 0).Interface()
+	}
+	return chansMap
+}
+
+// encodeWithVersion encodes value in the binary format of a protocol version
+func encodeWithVersion(t *testing.T, version byte, value reflect.Value) []byte {
+	t.Helper()
+	bcast.SetSendVersion(version)
+	defer bcast.SetSendVersion(bcast.PROTOCOL_VERSION)
+	packet, err := bcast.EncodePacket(bcast.BinaryFormat, value)
+	if err != nil {
+		t.Fatalf("encoding '%s' with version %d: %v", value.Type(), version, err)
+	}
+	return packet
+}
+
+func TestRoundTripRegisteredTypes(t *testing.T) {
+	if len(bcast.RegisteredTypes()) == 0 {
+		t.Fatal("no registered message types")
+	}
+	chansMap := registeredChans()
+	rng := rand.New(rand.NewSource(1))
+
+	for id, typ := range bcast.RegisteredTypes() {
+		for i := 0; i < 20; i++ {
+			value := reflect.New(typ).Elem()
+			fill(value, rng)
+
+			for version := bcast.MIN_PROTOCOL_VERSION; version <= bcast.PROTOCOL_VERSION; version++ {
+				packet := encodeWithVersion(t, version, value)
+				if packet[0] != version {
+					t.Fatalf("'%s' encoded with version %d starts with %d", typ, version, packet[0])
+				}
+
+				ch, decoded, err := bcast.DecodePacket(packet, chansMap)
+				if err != nil {
+					t.Fatalf("decoding '%s' (id %d) of version %d: %v", typ, id, version, err)
+				}
+				if ch != chansMap[typ.String()] {
+					t.Errorf("'%s' decoded for the channel of another type", typ)
+				}
+				want := reflect.New(typ).Elem()
+				want.Set(value)
+				zeroAfter(want, version)
+				if !reflect.DeepEqual(decoded.Interface(), want.Interface()) {
+					t.Errorf("'%s' of version %d:\n decoded %+v\n want    %+v", typ, version, decoded.Interface(), want.Interface())
+				}
+			}
+
+			packet, err := bcast.EncodePacket(bcast.JSONFormat, value)
+			if err != nil {
+				t.Fatalf("encoding '%s' as JSON: %v", typ, err)
+			}
+			_, decoded, err := bcast.DecodePacket(packet, chansMap)
+			if err != nil {
+				t.Fatalf("decoding '%s' from JSON: %v", typ, err)
+			}
+			if !reflect.DeepEqual(decoded.Interface(), value.Interface()) {
+				t.Errorf("'%s' as JSON:\n decoded %+v\n want    %+v", typ, decoded.Interface(), value.Interface())
+			}
+		}
+	}
+}
+
+// TestOlderVersionLeavesOutNewerFields checks that the fields added after a version are not in its packets at all
+func TestOlderVersionLeavesOutNewerFields(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	tagged := 0
+	for _, typ := range bcast.RegisteredTypes() {
+		value := reflect.New(typ).Elem()
+		fill(value, rng)
+
+		for version := bcast.MIN_PROTOCOL_VERSION; version < bcast.PROTOCOL_VERSION; version++ {
+			withoutNewer := reflect.New(typ).Elem()
+			withoutNewer.Set(value)
+			zeroAfter(withoutNewer, version)
+			if reflect.DeepEqual(withoutNewer.Interface(), value.Interface()) {
+				continue
+			}
+			tagged++
+
+			if got, want := encodeWithVersion(t, version, value), encodeWithVersion(t, version, withoutNewer); !reflect.DeepEqual(got, want) {
+				t.Errorf("'%s' of version %d depends on the fields added later:\n %x\n %x", typ, version, got, want)
+			}
+			if older, newer := encodeWithVersion(t, version, value), encodeWithVersion(t, bcast.PROTOCOL_VERSION, value); len(older) >= len(newer) {
+				t.Errorf("'%s' of version %d is %d bytes, not shorter than %d bytes with version %d",
+					typ, version, len(older), len(newer), bcast.PROTOCOL_VERSION)
+			}
+		}
+	}
+	if tagged == 0 {
+		t.Fatal("no registered type has fields added after the oldest version")
+	}
+}
+
+func TestDecodeTruncatedPackets(t *testing.T) {
+	chansMap := registeredChans()
+	rng := rand.New(rand.NewSource(3))
+	for _, typ := range bcast.RegisteredTypes() {
+		value := reflect.New(typ).Elem()
+		fill(value, rng)
+
+		for _, format := range []bcast.WireFormat{bcast.BinaryFormat, bcast.JSONFormat} {
+			packet, err := bcast.EncodePacket(format, value)
+			if err != nil {
+				t.Fatalf("encoding '%s' as %v: %v", typ, format, err)
+			}
+			for n := 0; n < len(packet); n++ {
+				if _, _, err := bcast.DecodePacket(packet[:n], chansMap); err == nil {
+					t.Errorf("'%s' as %v cut to %d of %d bytes decoded without an error", typ, format, n, len(packet))
+				}
+			}
+			if _, _, err := bcast.DecodePacket(append(packet[:len(packet):len(packet)], 0), chansMap); err == nil && format == bcast.BinaryFormat {
+				t.Errorf("'%s' with a byte left over decoded without an error", typ)
+			}
+		}
+	}
+}
+
+func TestDecodeGarbage(t *testing.T) {
+	chansMap := registeredChans()
+	garbage := map[string][]byte{
+		"empty":                     {},
+		"unknown version":           {0, 1},
+		"version from the future":   {bcast.PROTOCOL_VERSION + 1, 1},
+		"no type id":                {bcast.PROTOCOL_VERSION},
+		"unterminated type id":      {bcast.PROTOCOL_VERSION, 0x80},
+		"unknown type id":           {bcast.PROTOCOL_VERSION, 0x7f},
+		"length beyond the packet":  {bcast.PROTOCOL_VERSION, 9, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		"overlong varint":           append([]byte{bcast.PROTOCOL_VERSION, 1}, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01),
+		"malformed JSON":            []byte(`{"TypeId":`),
+		"JSON of an unknown type":   []byte(`{"TypeId":"main.Unknown","JSON":"e30="}`),
+		"JSON that does not decode": []byte(`{"TypeId":"messages.Ack","JSON":"WzFd"}`),
+	}
+	for name, packet := range garbage {
+		if _, _, err := bcast.DecodePacket(packet, chansMap); err == nil {
+			t.Errorf("%s: decoded without an error", name)
+		}
+	}
+
+	// random bytes after a valid header must never panic, whether they decode or not
+	rng := rand.New(rand.NewSource(4))
+	ids := make([]uint64, 0, len(bcast.RegisteredTypes()))
+	for id := range bcast.RegisteredTypes() {
+		ids = append(ids, id)
+	}
+	for i := 0; i < 20000; i++ {
+		packet := []byte{bcast.MIN_PROTOCOL_VERSION + byte(rng.Intn(int(bcast.PROTOCOL_VERSION-bcast.MIN_PROTOCOL_VERSION+1))), byte(ids[rng.Intn(len(ids))])}
+		body := make([]byte, rng.Intn(64))
+		rng.Read(body)
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("decoding %x panicked: %v", append(packet, body...), r)
+				}
+			}()
+			bcast.DecodePacket(append(packet, body...), chansMap)
+		}()
+	}
+}
@@ -0,0 +1,15 @@
+package bcast
+
+import "reflect"
+
+// Exported for the tests in package bcast_test, which can import the message types registered in package messages
+var (
+	EncodePacket = encodePacket
+	DecodePacket = decodePacket
+	FieldSince   = fieldSince
+)
+
+// RegisteredTypes returns the registered message types by id
+func RegisteredTypes() map[uint64]reflect.Type {
+	return typesByID
+}
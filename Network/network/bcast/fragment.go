@@ -0,0 +1,182 @@
+package bcast
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Packets longer than BUF_SIZE are split into fragments that each fit in a datagram:
+//
+//	FRAGMENT_MAGIC | message id (4 bytes) | index (2 bytes) | count (2 bytes) | part of the packet
+//
+// Every fragment is signed on its own when authentication is on. The Receiver puts the packet together again when
+// it has all the fragments, and drops it if they do not all arrive within REASSEMBLY_TIMEOUT.
+const (
+	FRAGMENT_MAGIC          byte = 0xF7 // neither '{', a protocol version nor AUTH_MAGIC
+	MAX_MESSAGE_SIZE             = 64 * 1024
+	REASSEMBLY_TIMEOUT           = 500 * time.Millisecond
+	REASSEMBLY_MEMORY_LIMIT      = 256 * 1024 // bytes held in partly received messages, from all senders
+	fragmentHeaderSize           = 1 + 4 + 2 + 2
+
+	// every fragment but the last fills a datagram, also when it is signed
+	maxFragments = MAX_MESSAGE_SIZE/(BUF_SIZE-authHeaderSize-authMACSize-fragmentHeaderSize) + 1
+)
+
+var (
+	errTooLarge          = fmt.Errorf("message longer than %d bytes", MAX_MESSAGE_SIZE)
+	errBadFragment       = errors.New("malformed fragment")
+	errReassemblyMemory  = fmt.Errorf("partly received messages would take more than %d bytes", REASSEMBLY_MEMORY_LIMIT)
+	errReassemblyTimeout = fmt.Errorf("message not complete within %v", REASSEMBLY_TIMEOUT)
+)
+
+// fragmenter splits packets into fragments with message ids unique to the sender
+type fragmenter struct {
+	nextID uint32
+}
+
+func newFragmenter() *fragmenter {
+	// a random start, so a restarted node does not reuse the ids of the fragments it sent before
+	return &fragmenter{nextID: rand.Uint32()}
+}
+
+// split returns the packet as fragments of at most maxSize bytes, or the packet itself if it fits
+func (f *fragmenter) split(packet []byte, maxSize int) ([][]byte, error) {
+	if len(packet) <= maxSize {
+		return [][]byte{packet}, nil
+	}
+	if len(packet) > MAX_MESSAGE_SIZE {
+		return nil, errTooLarge
+	}
+
+	partSize := maxSize - fragmentHeaderSize
+	count := (len(packet) + partSize - 1) / partSize
+	id := f.nextID
+	f.nextID++
+
+	fragments := make([][]byte, 0, count)
+	for index := 0; index < count; index++ {
+		part := packet[index*partSize : min((index+1)*partSize, len(packet))]
+		fragment := make([]byte, 0, fragmentHeaderSize+len(part))
+		fragment = append(fragment, FRAGMENT_MAGIC)
+		fragment = binary.BigEndian.AppendUint32(fragment, id)
+		fragment = binary.BigEndian.AppendUint16(fragment, uint16(index))
+		fragment = binary.BigEndian.AppendUint16(fragment, uint16(count))
+		fragments = append(fragments, append(fragment, part...))
+	}
+	return fragments, nil
+}
+
+func isFragment(packet []byte) bool {
+	return len(packet) > 0 && packet[0] == FRAGMENT_MAGIC
+}
+
+type fragmentKey struct {
+	sender string
+	id     uint32
+}
+
+type partialMessage struct {
+	parts    [][]byte
+	received int
+	size     int
+	started  time.Time
+}
+
+// reassembler puts fragmented packets together again
+type reassembler struct {
+	partial   map[fragmentKey]*partialMessage
+	completed map[fragmentKey]time.Time // when the recent messages were completed, their late duplicates are ignored
+	buffered  int                       // bytes held in partial
+}
+
+func newReassembler() *reassembler {
+	return &reassembler{partial: make(map[fragmentKey]*partialMessage), completed: make(map[fragmentKey]time.Time)}
+}
+
+// add adds a fragment from sender. It returns the packet when the fragment completes it, and nil until then.
+func (r *reassembler) add(sender string, fragment []byte, now time.Time) ([]byte, error) {
+	if len(fragment) <= fragmentHeaderSize {
+		return nil, errBadFragment
+	}
+	key := fragmentKey{sender, binary.BigEndian.Uint32(fragment[1:5])}
+	index := int(binary.BigEndian.Uint16(fragment[5:7]))
+	count := int(binary.BigEndian.Uint16(fragment[7:9]))
+	part := fragment[fragmentHeaderSize:]
+	if index >= count {
+		return nil, errBadFragment
+	}
+	if count > maxFragments {
+		return nil, errTooLarge
+	}
+
+	if _, ok := r.completed[key]; ok {
+		// a duplicate of a fragment of a message that has been delivered
+		return nil, nil
+	}
+	msg, ok := r.partial[key]
+	if !ok {
+		msg = &partialMessage{parts: make([][]byte, count), started: now}
+		r.partial[key] = msg
+	}
+	if count != len(msg.parts) {
+		r.remove(key)
+		return nil, errBadFragment
+	}
+	if msg.parts[index] != nil {
+		// a duplicate
+		return nil, nil
+	}
+	if msg.size+len(part) > MAX_MESSAGE_SIZE {
+		r.remove(key)
+		return nil, errTooLarge
+	}
+	if r.buffered+len(part) > REASSEMBLY_MEMORY_LIMIT {
+		r.remove(key)
+		return nil, errReassemblyMemory
+	}
+
+	// the receive buffer is reused for the next datagram
+	msg.parts[index] = append([]byte(nil), part...)
+	msg.received++
+	msg.size += len(part)
+	r.buffered += len(part)
+	if msg.received < len(msg.parts) {
+		return nil, nil
+	}
+
+	packet := make([]byte, 0, msg.size)
+	for _, part := range msg.parts {
+		packet = append(packet, part...)
+	}
+	r.remove(key)
+	r.completed[key] = now
+	return packet, nil
+}
+
+// expire drops the messages that have not been completed within REASSEMBLY_TIMEOUT, and returns how many.
+// The messages completed longer ago than that are forgotten.
+func (r *reassembler) expire(now time.Time) int {
+	for key, completed := range r.completed {
+		if now.Sub(completed) > REASSEMBLY_TIMEOUT {
+			delete(r.completed, key)
+		}
+	}
+	expired := 0
+	for key, msg := range r.partial {
+		if now.Sub(msg.started) > REASSEMBLY_TIMEOUT {
+			r.remove(key)
+			expired++
+		}
+	}
+	return expired
+}
+
+func (r *reassembler) remove(key fragmentKey) {
+	if msg, ok := r.partial[key]; ok {
+		r.buffered -= msg.size
+		delete(r.partial, key)
+	}
+}
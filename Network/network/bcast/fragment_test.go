@@ -0,0 +1,243 @@
+package bcast
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// makeFragment makes a fragment with any header, including ones split would never make
+func makeFragment(id uint32, index int, count int, part []byte) []byte {
+	fragment := []byte{FRAGMENT_MAGIC}
+	fragment = binary.BigEndian.AppendUint32(fragment, id)
+	fragment = binary.BigEndian.AppendUint16(fragment, uint16(index))
+	fragment = binary.BigEndian.AppendUint16(fragment, uint16(count))
+	return append(fragment, part...)
+}
+
+func randomPacket(rng *rand.Rand, size int) []byte {
+	packet := make([]byte, size)
+	rng.Read(packet)
+	return packet
+}
+
+func TestSplitSmallPacket(t *testing.T) {
+	packet := []byte("fits in a datagram")
+	fragments, err := newFragmenter().split(packet, BUF_SIZE)
+	if err != nil || len(fragments) != 1 || !bytes.Equal(fragments[0], packet) {
+		t.Errorf("got %q, %v, want the packet itself", fragments, err)
+	}
+	if isFragment(packet) {
+		t.Error("a packet that fits is taken for a fragment")
+	}
+}
+
+func TestSplitTooLarge(t *testing.T) {
+	if _, err := newFragmenter().split(make([]byte, MAX_MESSAGE_SIZE+1), BUF_SIZE); !errors.Is(err, errTooLarge) {
+		t.Errorf("got %v, want %v", err, errTooLarge)
+	}
+}
+
+func TestReassembleOutOfOrderAndDuplicates(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	packet := randomPacket(rng, 5*BUF_SIZE+17)
+	fragments, err := newFragmenter().split(packet, BUF_SIZE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every fragment twice, in random order
+	order := append(rng.Perm(len(fragments)), rng.Perm(len(fragments))...)
+	r := newReassembler()
+	now := time.Now()
+	var got []byte
+	for i, index := range order {
+		fragment := fragments[index]
+		if !isFragment(fragment) || len(fragment) > BUF_SIZE {
+			t.Fatalf("fragment %d of %d bytes", index, len(fragment))
+		}
+		packet, err := r.add("sender", fragment, now)
+		if err != nil {
+			t.Fatalf("fragment %d: %v", index, err)
+		}
+		if packet != nil {
+			if got != nil {
+				t.Fatal("the packet was completed twice")
+			}
+			got = packet
+			if i < len(fragments)-1 {
+				t.Fatalf("completed after %d of %d fragments", i+1, len(fragments))
+			}
+		}
+	}
+	if !bytes.Equal(got, packet) {
+		t.Error("the reassembled packet differs")
+	}
+	// the duplicates that come after the packet was completed are not taken for a new message
+	if len(r.partial) != 0 || r.buffered != 0 {
+		t.Errorf("%d partial messages, %d bytes left buffered", len(r.partial), r.buffered)
+	}
+
+	// until the message is forgotten
+	r.expire(now.Add(REASSEMBLY_TIMEOUT + time.Millisecond))
+	if _, err := r.add("sender", fragments[0], now.Add(REASSEMBLY_TIMEOUT+time.Millisecond)); err != nil || len(r.partial) != 1 {
+		t.Errorf("a fragment with the id of a forgotten message: %v, %d partial messages", err, len(r.partial))
+	}
+}
+
+func TestReassembleSendersApart(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	first, second := randomPacket(rng, 2*BUF_SIZE), randomPacket(rng, 2*BUF_SIZE)
+	// two senders that happen to use the same message id
+	firstFragments, _ := (&fragmenter{nextID: 7}).split(first, BUF_SIZE)
+	secondFragments, _ := (&fragmenter{nextID: 7}).split(second, BUF_SIZE)
+
+	r := newReassembler()
+	now := time.Now()
+	for i := range firstFragments {
+		gotFirst, err := r.add("a", firstFragments[i], now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotSecond, err := r.add("b", secondFragments[i], now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == len(firstFragments)-1 && (!bytes.Equal(gotFirst, first) || !bytes.Equal(gotSecond, second)) {
+			t.Error("the packets of the two senders were mixed up")
+		}
+	}
+}
+
+func TestReassembleMalformed(t *testing.T) {
+	part := []byte("part")
+	tests := []struct {
+		name      string
+		fragments [][]byte
+		wantErr   error
+	}{
+		{"header only", [][]byte{makeFragment(1, 0, 2, nil)}, errBadFragment},
+		{"index beyond count", [][]byte{makeFragment(1, 2, 2, part)}, errBadFragment},
+		{"mismatched count", [][]byte{makeFragment(1, 0, 3, part), makeFragment(1, 1, 2, part)}, errBadFragment},
+		{"too many fragments", [][]byte{makeFragment(1, 0, maxFragments+1, part)}, errTooLarge},
+		{"longer than MAX_MESSAGE_SIZE", [][]byte{
+			makeFragment(1, 0, 2, make([]byte, MAX_MESSAGE_SIZE/2+1)),
+			makeFragment(1, 1, 2, make([]byte, MAX_MESSAGE_SIZE/2)),
+		}, errTooLarge},
+	}
+	for _, test := range tests {
+		r := newReassembler()
+		var err error
+		for _, fragment := range test.fragments {
+			if _, err = r.add("sender", fragment, time.Now()); err != nil {
+				break
+			}
+		}
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.wantErr)
+		}
+		// the message is dropped with everything received of it
+		if len(r.partial) != 0 || r.buffered != 0 {
+			t.Errorf("%s: %d partial messages, %d bytes left buffered", test.name, len(r.partial), r.buffered)
+		}
+	}
+}
+
+func TestReassemblyMemoryLimit(t *testing.T) {
+	r := newReassembler()
+	// parts that do not add up to the limit, so there is room left to complete the messages
+	part := make([]byte, 30000)
+	senders := 0
+	var err error
+	for ; senders < 2*REASSEMBLY_MEMORY_LIMIT/len(part); senders++ {
+		// the first half of a message from every sender
+		if _, err = r.add(string(rune('a'+senders)), makeFragment(1, 0, 2, part), time.Now()); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, errReassemblyMemory) {
+		t.Fatalf("got %v, want %v", err, errReassemblyMemory)
+	}
+	if senders != REASSEMBLY_MEMORY_LIMIT/len(part) {
+		t.Errorf("memory limit reached after %d messages of %d bytes", senders, len(part))
+	}
+	if r.buffered > REASSEMBLY_MEMORY_LIMIT || r.buffered != senders*len(part) {
+		t.Errorf("%d bytes buffered", r.buffered)
+	}
+
+	// the messages already buffered can still be completed
+	packet, err := r.add("a", makeFragment(1, 1, 2, []byte("end")), time.Now())
+	if err != nil || len(packet) != len(part)+3 {
+		t.Errorf("completing a buffered message: %d bytes, %v", len(packet), err)
+	}
+}
+
+func TestReassemblyExpire(t *testing.T) {
+	r := newReassembler()
+	start := time.Now()
+	r.add("a", makeFragment(1, 0, 2, []byte("first half")), start)
+	r.add("b", makeFragment(1, 0, 2, []byte("first half")), start.Add(REASSEMBLY_TIMEOUT/2))
+
+	if expired := r.expire(start.Add(REASSEMBLY_TIMEOUT)); expired != 0 {
+		t.Errorf("%d messages expired within the timeout", expired)
+	}
+	if expired := r.expire(start.Add(REASSEMBLY_TIMEOUT + time.Millisecond)); expired != 1 {
+		t.Errorf("%d messages expired, want 1", expired)
+	}
+	if packet, err := r.add("a", makeFragment(1, 1, 2, []byte("second half")), start.Add(REASSEMBLY_TIMEOUT)); packet != nil || err != nil {
+		t.Errorf("an expired message was completed: %q, %v", packet, err)
+	}
+	// the late second half started a new message
+	if expired := r.expire(start.Add(2*REASSEMBLY_TIMEOUT + time.Millisecond)); expired != 2 {
+		t.Errorf("%d messages expired, want 2", expired)
+	}
+	if len(r.partial) != 0 || r.buffered != 0 {
+		t.Errorf("%d partial messages, %d bytes left buffered", len(r.partial), r.buffered)
+	}
+}
+
+// TestSignedFragmentsRoundTrip sends a message of the largest size through the path of the Broadcaster and the Receiver
+func TestSignedFragmentsRoundTrip(t *testing.T) {
+	sender := NewAuthenticator(testKey, testWindow)
+	receiver := NewAuthenticator(testKey, testWindow)
+	rng := rand.New(rand.NewSource(3))
+	packet := randomPacket(rng, MAX_MESSAGE_SIZE)
+
+	// as in the Broadcaster, the fragments leave room for the signature
+	fragments, err := newFragmenter().split(packet, BUF_SIZE-authHeaderSize-authMACSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fragments) > maxFragments {
+		t.Fatalf("%d fragments, at most %d are accepted", len(fragments), maxFragments)
+	}
+
+	r := newReassembler()
+	var got []byte
+	for i, fragment := range fragments {
+		signed := sender.sign(fragment)
+		if len(signed) > BUF_SIZE {
+			t.Fatalf("signed fragment %d is %d bytes, longer than BUF_SIZE", i, len(signed))
+		}
+		if i < len(fragments)-1 && len(signed) != BUF_SIZE {
+			t.Errorf("signed fragment %d is %d bytes, does not fill a datagram", i, len(signed))
+		}
+
+		verified, err := receiver.verify(signed)
+		if err != nil {
+			t.Fatalf("fragment %d: %v", i, err)
+		}
+		if got, err = r.add("sender", verified, time.Now()); err != nil {
+			t.Fatalf("fragment %d: %v", i, err)
+		}
+	}
+	if !bytes.Equal(got, packet) {
+		t.Error("the reassembled packet differs")
+	}
+	if stats := receiver.Stats(); stats.Accepted != uint64(len(fragments)) || stats.Rejected() != 0 {
+		t.Errorf("got stats %v", stats)
+	}
+}
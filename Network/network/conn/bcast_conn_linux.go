@@ -38,3 +38,8 @@ func DialBroadcastUDP(port int) net.PacketConn {
 
 	return conn
 }
+
+// setMulticastTTL sets how many routers the multicast packets sent on the socket may cross
+func setMulticastTTL(descriptor uintptr, ttl int) error {
+	return syscall.SetsockoptInt(int(descriptor), syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, ttl)
+}
@@ -0,0 +1,132 @@
+package conn
+
+import (
+	"elev/config"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Transport is how the packets reach the other nodes
+type Transport int
+
+const (
+	BroadcastTransport Transport = iota // broadcast, all nodes must be on the same network segment
+	UnicastTransport                    // a copy of every packet to each of the static peers, works across routed networks
+	MulticastTransport                  // to a multicast group, the routers between the nodes must forward the group
+)
+
+const (
+	BroadcastTransportName = "broadcast"
+	UnicastTransportName   = "unicast"
+	MulticastTransportName = "multicast"
+)
+
+func TransportFromName(name string) (Transport, error) {
+	switch name {
+	case BroadcastTransportName:
+		return BroadcastTransport, nil
+	case UnicastTransportName:
+		return UnicastTransport, nil
+	case MulticastTransportName:
+		return MulticastTransport, nil
+	default:
+		return BroadcastTransport, fmt.Errorf("unknown transport %q", name)
+	}
+}
+
+// DialTransport opens a UDP connection to send on port with the transport in config.TRANSPORT, and returns it
+// with the addresses to send a packet to for it to reach all nodes. Like with broadcast, a node receives its
+// own packets, with unicast only if its own host is one of config.STATIC_PEERS.
+func DialTransport(port int) (net.PacketConn, []net.Addr) {
+	switch configuredTransport() {
+	case UnicastTransport:
+		// unicast packets reach a single one of the sockets bound to a port, so we send from an ephemeral port
+		// that is never read, and leave the bound port to the receiver
+		conn, err := net.ListenPacket("udp4", ":0")
+		if err != nil {
+			panic(fmt.Sprintf("Could not open a UDP socket to send from: %v", err))
+		}
+		return conn, resolvePeers(config.STATIC_PEERS(), port)
+
+	case MulticastTransport:
+		conn, group, err := dialMulticastUDP(config.MULTICAST_GROUP, port)
+		if err == nil {
+			return conn, []net.Addr{group}
+		}
+		fmt.Printf("Error: multicast group %s: %v, falling back to broadcast\n", config.MULTICAST_GROUP, err)
+	}
+
+	addr, _ := net.ResolveUDPAddr("udp4", fmt.Sprintf("255.255.255.255:%d", port))
+	return DialBroadcastUDP(port), []net.Addr{addr}
+}
+
+// ListenTransport opens a UDP connection that receives the packets sent to port with the transport in config.TRANSPORT.
+// With unicast, a second node on the same host would get an arbitrary share of the packets sent to port.
+func ListenTransport(port int) net.PacketConn {
+	if configuredTransport() == MulticastTransport {
+		conn, _, err := dialMulticastUDP(config.MULTICAST_GROUP, port)
+		if err == nil {
+			return conn
+		}
+		fmt.Printf("Error: multicast group %s: %v, falling back to broadcast\n", config.MULTICAST_GROUP, err)
+	}
+	return DialBroadcastUDP(port)
+}
+
+func configuredTransport() Transport {
+	transport, err := TransportFromName(config.TRANSPORT)
+	if err != nil {
+		fmt.Printf("Error: %v, falling back to broadcast\n", err)
+	}
+	return transport
+}
+
+// resolvePeers resolves the peer addresses, a peer without a port is sent to on port.
+// Peers that cannot be resolved are reported and left out.
+func resolvePeers(peers []string, port int) []net.Addr {
+	addrs := make([]net.Addr, 0, len(peers))
+	for _, peer := range peers {
+		host, peerPort, err := net.SplitHostPort(peer)
+		if err != nil {
+			host, peerPort = peer, strconv.Itoa(port)
+		}
+		addr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(host, peerPort))
+		if err != nil {
+			fmt.Printf("Error: peer %s: %v\n", peer, err)
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// dialMulticastUDP joins the multicast group on port, and sets how many routers the packets sent may cross
+func dialMulticastUDP(group string, port int) (net.PacketConn, net.Addr, error) {
+	groupAddr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(group, strconv.Itoa(port)))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !groupAddr.IP.IsMulticast() {
+		return nil, nil, fmt.Errorf("%s is not a multicast address", group)
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", nil, groupAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	rawConn, err := conn.SyscallConn()
+	if err == nil {
+		controlErr := rawConn.Control(func(descriptor uintptr) {
+			err = setMulticastTTL(descriptor, config.MULTICAST_TTL)
+		})
+		if controlErr != nil {
+			err = controlErr
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("setting the multicast TTL: %v", err)
+	}
+	return conn, groupAddr, nil
+}
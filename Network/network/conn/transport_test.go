@@ -0,0 +1,51 @@
+package conn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransportFromName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Transport
+		wantErr bool
+	}{
+		{BroadcastTransportName, BroadcastTransport, false},
+		{UnicastTransportName, UnicastTransport, false},
+		{MulticastTransportName, MulticastTransport, false},
+		{"anycast", BroadcastTransport, true},
+		{"", BroadcastTransport, true},
+	}
+	for _, test := range tests {
+		got, err := TransportFromName(test.name)
+		if got != test.want || (err != nil) != test.wantErr {
+			t.Errorf("%q: got %v, %v", test.name, got, err)
+		}
+	}
+}
+
+func TestResolvePeers(t *testing.T) {
+	tests := []struct {
+		name  string
+		peers []string
+		want  []string
+	}{
+		{"a host without a port is sent to on the port of the packet", []string{"127.0.0.1"}, []string{"127.0.0.1:20011"}},
+		{"a host with a port keeps it", []string{"127.0.0.1:30000"}, []string{"127.0.0.1:30000"}},
+		{"host names are resolved", []string{"localhost:30001"}, []string{"127.0.0.1:30001"}},
+		{"an IPv6 peer is skipped, the sockets are IPv4", []string{"[::1]:30002"}, nil},
+		{"an invalid peer is skipped", []string{"127.0.0.1:notaport", "127.0.0.2"}, []string{"127.0.0.2:20011"}},
+		{"no peers", nil, nil},
+	}
+	for _, test := range tests {
+		addrs := resolvePeers(test.peers, 20011)
+		var got []string
+		for _, addr := range addrs {
+			got = append(got, addr.String())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -2,8 +2,6 @@ package peers
 
 import (
 	"elev/Network/network/conn"
-	"fmt"
-	"net"
 	"sort"
 	"time"
 )
@@ -17,12 +15,11 @@ type PeerUpdate struct {
 const INTERVAL = 15 * time.Millisecond
 const TIMEOUT = 500 * time.Millisecond
 
-// Transmitter broadcasts the given ID on the specified port at regular intervals.
+// Transmitter sends the given ID to all nodes on the specified port at regular intervals.
 // The transmission can be enabled or disabled using the transmitEnable channel.
 func Transmitter(port int, id string, transmitEnable <-chan bool) {
 
-	conn := conn.DialBroadcastUDP(port)
-	addr, _ := net.ResolveUDPAddr("udp4", fmt.Sprintf("255.255.255.255:%d", port))
+	conn, addrs := conn.DialTransport(port)
 
 	enable := true
 	for {
@@ -31,12 +28,14 @@ func Transmitter(port int, id string, transmitEnable <-chan bool) {
 		case <-time.After(INTERVAL):
 		}
 		if enable {
-			conn.WriteTo([]byte(id), addr)
+			for _, addr := range addrs {
+				conn.WriteTo([]byte(id), addr)
+			}
 		}
 	}
 }
 
-// Receiver listens for broadcasts on the specified port and updates the peer list.
+// Receiver listens for the IDs of the other nodes on the specified port and updates the peer list.
 // It sends updates to the peerUpdateCh channel whenever there are changes in the peer list.
 func Receiver(port int, peerUpdateCh chan<- PeerUpdate) {
 
@@ -44,7 +43,7 @@ func Receiver(port int, peerUpdateCh chan<- PeerUpdate) {
 	var p PeerUpdate
 	lastSeen := make(map[string]time.Time)
 
-	conn := conn.DialBroadcastUDP(port)
+	conn := conn.ListenTransport(port)
 
 	for {
 		updated := false
@@ -0,0 +1,182 @@
+// Command hracompare verifies the native hall request assigner against the hall request assigner executable,
+// run with wine on other systems than Windows.
+//
+// Every entry of the corpus is run through the native assigner and compared to the output recorded in
+// the entry, if any, and to the output of the executable, if it is available. Random inputs can be added
+// to the comparison with -random. With -record, the corpus is written back with the output of the executable
+// as the expected output of every entry, the random inputs included. Run it from the repository root.
+// It exits with status 1 on any mismatch.
+package main
+
+import (
+	"bufio"
+	"elev/config"
+	"elev/costFNS/hallRequestAssigner"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"strconv"
+)
+
+func main() {
+	corpusPath := flag.String("corpus", "costFNS/hallRequestAssigner/corpus.jsonl", "corpus file with one entry of JSON per line")
+	executable := flag.String("exe", hallRequestAssigner.DefaultHRAExecutable(), "hall request assigner executable")
+	numRandom := flag.Int("random", 0, "number of random inputs to compare in addition to the corpus")
+	seed := flag.Int64("seed", 1, "seed for the random inputs")
+	record := flag.Bool("record", false, "write the corpus back with the output of the executable as the expected outputs")
+	flag.Parse()
+
+	entries, err := readCorpus(*corpusPath)
+	if err != nil {
+		fmt.Println("Could not read the corpus:", err)
+		os.Exit(1)
+	}
+	rng := rand.New(rand.NewSource(*seed))
+	for i := 0; i < *numRandom; i++ {
+		entries = append(entries, hallRequestAssigner.HRACorpusEntry{Input: randomInput(rng)})
+	}
+
+	useExecutable := true
+	if err := hallRequestAssigner.CheckHRAexecutable(*executable); err != nil {
+		if *record {
+			fmt.Printf("Cannot record the outputs of the executable: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Cannot run the executable: %v, only comparing with the recorded outputs\n", err)
+		useExecutable = false
+	}
+
+	cfg := hallRequestAssigner.DefaultHRAConfig()
+	compared, mismatches, unchecked := 0, 0, 0
+	for i := range entries {
+		entry := entries[i]
+		native, nativeErr := hallRequestAssigner.OptimalHallRequests(entry.Input, cfg)
+
+		if entry.Expected != nil {
+			compared++
+			if nativeErr != nil || !reflect.DeepEqual(native, entry.Expected) {
+				mismatches++
+				report(i, entry.Input, "recorded", entry.Expected, nil, native, nativeErr)
+			}
+		}
+
+		if useExecutable {
+			reference, referenceErr := hallRequestAssigner.HRAexecutable(*executable, entry.Input)
+			compared++
+			// both must fail on invalid input, or both succeed with the same assignments
+			if (referenceErr != nil) != (nativeErr != nil) || !reflect.DeepEqual(native, reference) {
+				mismatches++
+				report(i, entry.Input, "executable", reference, referenceErr, native, nativeErr)
+			}
+			if referenceErr == nil {
+				entries[i].Expected, entries[i].Source = reference, hallRequestAssigner.SourceExecutable
+			}
+		} else if entry.Expected == nil {
+			unchecked++
+		}
+	}
+
+	fmt.Printf("%d entries, %d comparisons, %d mismatches\n", len(entries), compared, mismatches)
+	if unchecked > 0 {
+		fmt.Printf("%d entries not checked, they have no recorded output and the executable is not available\n", unchecked)
+	}
+	if *record {
+		if err := writeCorpus(*corpusPath, entries); err != nil {
+			fmt.Println("Could not write the corpus:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Recorded %d entries in %s\n", len(entries), *corpusPath)
+	}
+	if mismatches > 0 {
+		os.Exit(1)
+	}
+}
+
+func readCorpus(path string) ([]hallRequestAssigner.HRACorpusEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []hallRequestAssigner.HRACorpusEntry
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry hallRequestAssigner.HRACorpusEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func writeCorpus(path string, entries []hallRequestAssigner.HRACorpusEntry) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, entry := range entries {
+		jsonBytes, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		writer.Write(append(jsonBytes, '\n'))
+	}
+	return writer.Flush()
+}
+
+// randomInput makes a valid input with one to three elevators
+func randomInput(rng *rand.Rand) hallRequestAssigner.HRAInput {
+	input := hallRequestAssigner.HRAInput{States: make(map[string]hallRequestAssigner.HRAElevState)}
+	for floor := 0; floor < config.NUM_FLOORS; floor++ {
+		input.HallRequests[floor][0] = floor < config.NUM_FLOORS-1 && rng.Intn(3) == 0
+		input.HallRequests[floor][1] = floor > 0 && rng.Intn(3) == 0
+	}
+
+	numElevators := 1 + rng.Intn(3)
+	for i := 0; i < numElevators; i++ {
+		state := hallRequestAssigner.HRAElevState{Floor: rng.Intn(config.NUM_FLOORS)}
+		for floor := range state.CabRequests {
+			state.CabRequests[floor] = rng.Intn(4) == 0
+		}
+
+		switch rng.Intn(3) {
+		case 0:
+			state.Behavior, state.Direction = "idle", "stop"
+		case 1:
+			state.Behavior, state.Direction = "doorOpen", []string{"up", "down", "stop"}[rng.Intn(3)]
+		default:
+			state.Behavior = "moving"
+			switch {
+			case state.Floor == 0:
+				state.Direction = "up"
+			case state.Floor == config.NUM_FLOORS-1:
+				state.Direction = "down"
+			default:
+				state.Direction = []string{"up", "down"}[rng.Intn(2)]
+			}
+		}
+		input.States[strconv.Itoa(i+1)] = state
+	}
+	return input
+}
+
+func report(index int, input hallRequestAssigner.HRAInput, referenceName string,
+	reference map[string][config.NUM_FLOORS][2]bool, referenceErr error,
+	native map[string][config.NUM_FLOORS][2]bool, nativeErr error) {
+
+	inputJSON, _ := json.Marshal(input)
+	fmt.Printf("Mismatch on entry %d: %s\n", index, inputJSON)
+	fmt.Printf("  %s: %v %v\n", referenceName, reference, referenceErr)
+	fmt.Printf("  native: %v %v\n", native, nativeErr)
+}
@@ -0,0 +1,93 @@
+// Command simulator runs an elevator hardware simulator that speaks the elevio TCP protocol.
+//
+// Buttons, the stop button and the obstruction switch are injected by typing commands on stdin:
+//
+//	u <floor>   press hall up
+//	d <floor>   press hall down
+//	c <floor>   press cab
+//	s           press stop
+//	o           toggle obstruction
+//	p           print the hardware state
+package main
+
+import (
+	"bufio"
+	"elev/simulator"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	cfg := simulator.DefaultConfig()
+	port := flag.Int("port", 15657, "TCP port to listen on")
+	flag.IntVar(&cfg.NumFloors, "floors", cfg.NumFloors, "number of floors")
+	flag.DurationVar(&cfg.TravelDuration, "travel", cfg.TravelDuration, "travel time between two floors")
+	flag.DurationVar(&cfg.ButtonPressDuration, "press", cfg.ButtonPressDuration, "how long an injected button press is held")
+	flag.IntVar(&cfg.StartFloor, "start", cfg.StartFloor, "start floor, -1 starts between floors")
+	flag.Parse()
+
+	sim := simulator.New(cfg)
+	go sim.Run()
+	go readCommands(sim)
+
+	if err := sim.ListenAndServe(fmt.Sprintf("localhost:%d", *port)); err != nil {
+		fmt.Println("Simulator stopped:", err)
+		os.Exit(1)
+	}
+}
+
+func readCommands(sim *simulator.Simulator) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "u", "d", "c":
+			if len(fields) != 2 {
+				fmt.Println("usage: u|d|c <floor>")
+				continue
+			}
+			floor, err := strconv.Atoi(fields[1])
+			if err != nil {
+				fmt.Println("invalid floor:", fields[1])
+				continue
+			}
+			button := map[string]int{"u": simulator.ButtonHallUp, "d": simulator.ButtonHallDown, "c": simulator.ButtonCab}[fields[0]]
+			if err := sim.PressButton(button, floor); err != nil {
+				fmt.Println(err)
+			}
+		case "s":
+			sim.PressStop()
+		case "o":
+			sim.ToggleObstruction()
+		case "p":
+			printState(sim.Snapshot())
+		default:
+			fmt.Println("unknown command, use u|d|c <floor>, s, o or p")
+		}
+	}
+}
+
+func printState(state simulator.State) {
+	fmt.Printf("Position: %.2f, motor: %d, floor sensor: %d, floor indicator: %d\n",
+		state.Position, state.MotorDirection, state.FloorSensor, state.FloorIndicator)
+	fmt.Printf("Door lamp: %t, stop lamp: %t, obstructed: %t\n", state.DoorOpenLamp, state.StopLamp, state.Obstructed)
+	fmt.Println("Lamps (up down cab):")
+	for floor := len(state.ButtonLamps) - 1; floor >= 0; floor-- {
+		fmt.Printf("Floor %d: ", floor)
+		for _, lamp := range state.ButtonLamps[floor] {
+			if lamp {
+				fmt.Print("# ")
+			} else {
+				fmt.Print("- ")
+			}
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,84 @@
+// Command trafficsim compares dispatch policies and timing parameters on synthetic traffic.
+//
+// Every combination of traffic pattern, hall assigner and door open time is simulated in virtual time with the
+// same passengers, and the wait time, ride time and number of stops are reported for each. For example:
+//
+//	trafficsim -patterns uppeak,random -assigners hra,nearest -door 2s,3s -elevators 3 -rate 12
+package main
+
+import (
+	"elev/config"
+	"elev/costFNS/assigner"
+	"elev/elevator"
+	"elev/trafficsim"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func main() {
+	cfg := trafficsim.DefaultConfig()
+	patterns := flag.String("patterns", strings.Join(trafficsim.Patterns, ","), "traffic patterns to simulate")
+	assigners := flag.String("assigners", strings.Join([]string{assigner.HRAAssignerName, assigner.NearestCarAssignerName,
+		assigner.RoundRobinAssignerName, assigner.ZoningAssignerName}, ","), "hall assigners to compare")
+	doorDurations := flag.String("door", config.DOOR_OPEN_DURATION.String(), "door open times to compare")
+	strategyName := flag.String("strategy", config.REQUEST_STRATEGY, "how each car services its requests")
+	flag.IntVar(&cfg.NumElevators, "elevators", cfg.NumElevators, "number of elevators")
+	flag.Float64Var(&cfg.ArrivalRate, "rate", cfg.ArrivalRate, "passengers per minute")
+	flag.DurationVar(&cfg.Duration, "duration", cfg.Duration, "how long passengers keep arriving")
+	flag.DurationVar(&cfg.TravelDuration, "travel", cfg.TravelDuration, "travel time between two floors")
+	flag.Int64Var(&cfg.Seed, "seed", cfg.Seed, "seed for the traffic")
+	flag.Parse()
+
+	strategy, err := elevator.StrategyFromName(*strategyName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	cfg.Strategy = strategy
+
+	var doors []time.Duration
+	for _, s := range strings.Split(*doorDurations, ",") {
+		door, err := time.ParseDuration(s)
+		if err != nil {
+			fmt.Printf("Invalid door open time %q\n", s)
+			os.Exit(1)
+		}
+		doors = append(doors, door)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "pattern\tassigner\tdoor\tpassengers\tdelivered\twait avg\twait p95\tride avg\tride p95\tstops")
+	for _, pattern := range strings.Split(*patterns, ",") {
+		for _, name := range strings.Split(*assigners, ",") {
+			for _, door := range doors {
+				// every run gets a fresh assigner, as some of them remember earlier assignments
+				hallAssigner, err := assigner.AssignerFromName(name)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				cfg.Pattern = pattern
+				cfg.DoorOpenDuration = door
+				result, err := trafficsim.Run(cfg, hallAssigner)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%v\t%d\t%d\t%v\t%v\t%v\t%v\t%d\n", pattern, name, door,
+					result.Passengers, result.Delivered,
+					round(trafficsim.Average(result.WaitTimes)), round(trafficsim.Percentile(result.WaitTimes, 95)),
+					round(trafficsim.Average(result.RideTimes)), round(trafficsim.Percentile(result.RideTimes, 95)),
+					result.Stops)
+			}
+		}
+	}
+	w.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(100 * time.Millisecond)
+}
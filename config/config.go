@@ -6,8 +6,30 @@ import (
 
 const DOOR_OPEN_DURATION = 3 * time.Second
 const DOOR_STUCK_DURATION = 30 * time.Second
+const MOTOR_WATCHDOG_DURATION = 5 * time.Second // maximum time a move between two floors may take
 const NUM_FLOORS = 4
 const NUM_BUTTONS = 3
+const RECALL_FLOOR = 0                                  // default floor cars are sent to in a fire recall
+const HALL_ASSIGNER = "hra"                             // how the master distributes hall requests: "hra", "nearest", "roundrobin" or "zoning"
+const ASSIGNMENT_REASSIGN_THRESHOLD = 3 * time.Second   // a car on its way to a hall request only loses it to a car that is this much faster
+const ASSIGNMENT_TIMEOUT = 200 * time.Millisecond       // the hall assigner must answer within this, or the nearest car assigner is used
+const ASSIGNMENT_AUDIT_SIZE = 1000                      // number of hall assignment decisions kept for the "audit" operator command
+const DESTINATION_DISPATCH = false                      // if set, passengers enter their destination at the floor with "call <from> <to>" on the console
+const DESTINATION_MAX_CALLS = 6                         // destination calls waiting to be picked up, more do not fit in one broadcast
+const HALL_REQUEST_SLA = 60 * time.Second               // hall requests waiting longer are escalated to the nearest available car
+const HALL_REQUEST_SLA_CHECK_INTERVAL = 1 * time.Second // how often the master checks the waiting times of the hall requests
+const HRA_TRAVEL_DURATION = 2500 * time.Millisecond     // travel time between two floors assumed by the hall request assigner
+const HRA_DOOR_OPEN_DURATION = 3000 * time.Millisecond  // door open time assumed by the hall request assigner
+const HRA_RECORD_FILE = ""                              // if set, every hall request assigner input is appended to this file for verification with cmd/hracompare
+const MAINTENANCE_PARK_FLOOR = 0                        // default floor a car parks at with its door open in maintenance
+const PARKING_IDLE_TIMEOUT = 30 * time.Second           // how long a car stands idle before it parks at its home floor
+const REQUEST_STRATEGY = "collective"                   // how a single car services its requests: "collective", "clearall" or "scan"
+const CLUSTER_KEY_FILE = ""                             // if set, all packets are signed with the key in this file, shared by the cluster, and unsigned packets are dropped
+const AUTH_REPLAY_WINDOW = 2 * time.Second              // signed packets older than this, or replayed within it, are dropped. The clocks of the nodes must agree to well within it
+const TRANSPORT = "broadcast"                           // how packets reach the other nodes: "broadcast", "unicast" to STATIC_PEERS or "multicast" to MULTICAST_GROUP
+const MULTICAST_GROUP = "239.255.42.1"                  // the group joined by all nodes with the multicast transport
+const MULTICAST_TTL = 8                                 // how many routers multicast packets may cross
+const WIRE_FORMAT = "binary"                            // how messages are encoded on the network: "binary" or "json", both are always decoded
 const MSG_ID_PARTITION_SIZE = uint64(2 << 60)
 const MASTER_TRANSMIT_INTERVAL = 50 * time.Millisecond
 const ELEV_STATE_TRANSMIT_INTERVAL = 50 * time.Millisecond
@@ -24,3 +46,18 @@ const PORT_NUM = 20011
 const INPUT_POLL_INTERVAL = 25 * time.Millisecond
 const MASTER_TIMEOUT = 600 * time.Millisecond
 const PEER_POLL_INTERVAL = 30 * time.Millisecond
+
+const DRIVER_IO_TIMEOUT = 500 * time.Millisecond
+const DRIVER_RECONNECT_MIN_BACKOFF = 100 * time.Millisecond
+const DRIVER_RECONNECT_MAX_BACKOFF = 5 * time.Second
+
+// The settings that are lists are functions, so every caller gets its own slice to change.
+
+// Home floors for idle cars, spread across the active cars by the master: the first car parks at the lobby, the second mid-building.
+// A car on its own parks at the first home floor, so there must be at least one.
+func PARKING_HOME_FLOORS() []int { return []int{0, NUM_FLOORS / 2} }
+
+// The hosts of all nodes for the unicast transport. A host without a port is sent to on the port of the packet.
+// This node must be listed too, or it does not receive its own packets. As all nodes receive on the same ports,
+// only one node per host can use the unicast transport.
+func STATIC_PEERS() []string { return []string{"localhost"} }
@@ -0,0 +1,208 @@
+// Package assigner contains the dispatch policies the master can use to distribute hall requests.
+package assigner
+
+import (
+	"elev/config"
+	"elev/costFNS/hallRequestAssigner"
+	"elev/elevator"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Assigner distributes the hall requests among the elevators. The result has an entry for every elevator,
+// and every active hall request is assigned to exactly one of them.
+type Assigner interface {
+	Assign(elevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error)
+}
+
+// Assigner names used in configuration
+const (
+	HRAAssignerName        = "hra"
+	NearestCarAssignerName = "nearest"
+	RoundRobinAssignerName = "roundrobin"
+	ZoningAssignerName     = "zoning"
+)
+
+var errNoElevators = errors.New("no elevators to assign hall requests to")
+
+// AssignerFromName returns a new assigner with the given configuration name
+func AssignerFromName(name string) (Assigner, error) {
+	switch name {
+	case HRAAssignerName:
+		return HRAAssigner{}, nil
+	case NearestCarAssignerName:
+		return NearestCarAssigner{}, nil
+	case RoundRobinAssignerName:
+		return NewRoundRobinAssigner(), nil
+	case ZoningAssignerName:
+		return ZoningAssigner{}, nil
+	default:
+		return nil, fmt.Errorf("unknown hall assigner %q", name)
+	}
+}
+
+// HRAAssigner is the time-to-idle cost algorithm of the hall request assigner
+type HRAAssigner struct{}
+
+func (HRAAssigner) Assign(elevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+	if len(elevStates) == 0 {
+		return nil, errNoElevators
+	}
+	return hallRequestAssigner.HRAassign(hallRequestAssigner.MakeHRAInput(elevStates, hallRequests))
+}
+
+// NearestCarAssigner gives each hall request to the car with the best figure of suitability: cars close to the
+// request and already travelling towards it in the requested direction are preferred, cars moving away are the last resort.
+type NearestCarAssigner struct{}
+
+func (NearestCarAssigner) Assign(elevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+	ids := sortedIDs(elevStates)
+	if len(ids) == 0 {
+		return nil, errNoElevators
+	}
+	assignments := emptyAssignments(ids)
+
+	for floor := 0; floor < config.NUM_FLOORS; floor++ {
+		for btn := 0; btn < 2; btn++ {
+			if !hallRequests[floor][btn] {
+				continue
+			}
+			bestID, bestSuitability := ids[0], -1
+			for _, id := range ids {
+				if suitability := figureOfSuitability(elevStates[id], floor, elevator.ButtonType(btn)); suitability > bestSuitability {
+					bestID, bestSuitability = id, suitability
+				}
+			}
+			assign(assignments, bestID, floor, btn)
+		}
+	}
+	return assignments, nil
+}
+
+func figureOfSuitability(state elevator.ElevatorState, floor int, btn elevator.ButtonType) int {
+	if state.Floor < 0 {
+		// position unknown, only take the request if nobody else can
+		return 0
+	}
+	distance := floor - state.Floor
+	if distance < 0 {
+		distance = -distance
+	}
+
+	if state.Behavior != elevator.Moving {
+		return config.NUM_FLOORS + 1 - distance
+	}
+
+	towards := (state.Direction == elevator.DirectionUp && floor > state.Floor) ||
+		(state.Direction == elevator.DirectionDown && floor < state.Floor)
+	sameDirection := (state.Direction == elevator.DirectionUp && btn == elevator.ButtonHallUp) ||
+		(state.Direction == elevator.DirectionDown && btn == elevator.ButtonHallDown)
+	switch {
+	case towards && sameDirection:
+		return config.NUM_FLOORS + 2 - distance
+	case towards:
+		return config.NUM_FLOORS + 1 - distance
+	default:
+		return 1
+	}
+}
+
+// RoundRobinAssigner hands out new hall requests to the cars in turn. A request keeps its car
+// until it is served or the car leaves, so every car gets its share regardless of position.
+type RoundRobinAssigner struct {
+	assignedTo map[hallRequest]int
+	lastID     int
+}
+
+type hallRequest struct {
+	floor int
+	btn   int
+}
+
+func NewRoundRobinAssigner() *RoundRobinAssigner {
+	return &RoundRobinAssigner{assignedTo: make(map[hallRequest]int), lastID: -1}
+}
+
+func (rr *RoundRobinAssigner) Assign(elevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+	ids := sortedIDs(elevStates)
+	if len(ids) == 0 {
+		return nil, errNoElevators
+	}
+	assignments := emptyAssignments(ids)
+
+	for floor := 0; floor < config.NUM_FLOORS; floor++ {
+		for btn := 0; btn < 2; btn++ {
+			req := hallRequest{floor, btn}
+			if !hallRequests[floor][btn] {
+				delete(rr.assignedTo, req)
+				continue
+			}
+			id, ok := rr.assignedTo[req]
+			if _, active := elevStates[id]; !ok || !active {
+				id = rr.nextID(ids)
+				rr.assignedTo[req] = id
+			}
+			assign(assignments, id, floor, btn)
+		}
+	}
+	return assignments, nil
+}
+
+// nextID returns the first id after the last one that got a request, wrapping around
+func (rr *RoundRobinAssigner) nextID(ids []int) int {
+	next := ids[0]
+	for _, id := range ids {
+		if id > rr.lastID {
+			next = id
+			break
+		}
+	}
+	rr.lastID = next
+	return next
+}
+
+// ZoningAssigner splits the building into one zone of consecutive floors per car, in order of node id,
+// and gives every hall request to the car that owns its zone
+type ZoningAssigner struct{}
+
+func (ZoningAssigner) Assign(elevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+	ids := sortedIDs(elevStates)
+	if len(ids) == 0 {
+		return nil, errNoElevators
+	}
+	assignments := emptyAssignments(ids)
+
+	for floor := 0; floor < config.NUM_FLOORS; floor++ {
+		zone := floor * len(ids) / config.NUM_FLOORS
+		for btn := 0; btn < 2; btn++ {
+			if hallRequests[floor][btn] {
+				assign(assignments, ids[zone], floor, btn)
+			}
+		}
+	}
+	return assignments, nil
+}
+
+func sortedIDs(elevStates map[int]elevator.ElevatorState) []int {
+	ids := make([]int, 0, len(elevStates))
+	for id := range elevStates {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func emptyAssignments(ids []int) map[int][config.NUM_FLOORS][2]bool {
+	assignments := make(map[int][config.NUM_FLOORS][2]bool, len(ids))
+	for _, id := range ids {
+		assignments[id] = [config.NUM_FLOORS][2]bool{}
+	}
+	return assignments
+}
+
+func assign(assignments map[int][config.NUM_FLOORS][2]bool, id int, floor int, btn int) {
+	assignment := assignments[id]
+	assignment[floor][btn] = true
+	assignments[id] = assignment
+}
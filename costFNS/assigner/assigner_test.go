@@ -0,0 +1,154 @@
+package assigner
+
+import (
+	"elev/config"
+	"elev/elevator"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const up, down = int(elevator.ButtonHallUp), int(elevator.ButtonHallDown)
+
+func idle(floor int) elevator.ElevatorState {
+	return elevator.ElevatorState{Floor: floor, Direction: elevator.DirectionStop, Behavior: elevator.Idle}
+}
+
+func moving(floor int, dir elevator.MotorDirection) elevator.ElevatorState {
+	return elevator.ElevatorState{Floor: floor, Direction: dir, Behavior: elevator.Moving}
+}
+
+func doorOpen(floor int, dir elevator.MotorDirection) elevator.ElevatorState {
+	return elevator.ElevatorState{Floor: floor, Direction: dir, Behavior: elevator.DoorOpen}
+}
+
+// requests returns the hall requests, or an assignment, with the given requests set
+func requests(reqs ...hallRequest) [config.NUM_FLOORS][2]bool {
+	var set [config.NUM_FLOORS][2]bool
+	for _, req := range reqs {
+		set[req.floor][req.btn] = true
+	}
+	return set
+}
+
+// fixedAssigner returns a copy of the same assignments every time
+type fixedAssigner struct {
+	assignments map[int][config.NUM_FLOORS][2]bool
+	err         error
+}
+
+func (fa fixedAssigner) Assign(map[int]elevator.ElevatorState, [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+	if fa.assignments == nil {
+		return nil, fa.err
+	}
+	assignments := make(map[int][config.NUM_FLOORS][2]bool, len(fa.assignments))
+	for id, assignment := range fa.assignments {
+		assignments[id] = assignment
+	}
+	return assignments, fa.err
+}
+
+func TestAssignerFromName(t *testing.T) {
+	for _, name := range []string{HRAAssignerName, NearestCarAssignerName, RoundRobinAssignerName, ZoningAssignerName} {
+		if assigner, err := AssignerFromName(name); err != nil || assigner == nil {
+			t.Errorf("%s: got %v, %v", name, assigner, err)
+		}
+	}
+	if _, err := AssignerFromName("fastest"); err == nil {
+		t.Error("an unknown assigner name was accepted")
+	}
+}
+
+func TestAssignersWithoutElevators(t *testing.T) {
+	hallRequests := requests(hallRequest{1, up})
+	for _, assigner := range []Assigner{HRAAssigner{}, NearestCarAssigner{}, NewRoundRobinAssigner(), ZoningAssigner{}} {
+		if _, err := assigner.Assign(map[int]elevator.ElevatorState{}, hallRequests); !errors.Is(err, errNoElevators) {
+			t.Errorf("%T: got %v, want %v", assigner, err, errNoElevators)
+		}
+	}
+}
+
+func TestNearestCarAssigner(t *testing.T) {
+	tests := []struct {
+		name    string
+		states  map[int]elevator.ElevatorState
+		request hallRequest
+		wantID  int
+	}{
+		{"the closer idle car", map[int]elevator.ElevatorState{1: idle(0), 2: idle(3)}, hallRequest{2, up}, 2},
+		{"a car on its way in the requested direction before an idle car",
+			map[int]elevator.ElevatorState{1: moving(1, elevator.DirectionUp), 2: idle(3)}, hallRequest{2, up}, 1},
+		{"an idle car before a car moving away",
+			map[int]elevator.ElevatorState{1: moving(1, elevator.DirectionDown), 2: idle(3)}, hallRequest{2, up}, 2},
+		{"a car of known position before one of unknown position",
+			map[int]elevator.ElevatorState{1: idle(-1), 2: idle(3)}, hallRequest{0, up}, 2},
+		{"the lowest id on a tie", map[int]elevator.ElevatorState{1: idle(0), 2: idle(2)}, hallRequest{1, down}, 1},
+	}
+	for _, test := range tests {
+		assignments, err := NearestCarAssigner{}.Assign(test.states, requests(test.request))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(assignments) != len(test.states) {
+			t.Errorf("%s: %d assignments for %d cars", test.name, len(assignments), len(test.states))
+		}
+		if got := ownerOf(assignments, test.request.floor, test.request.btn); got != test.wantID {
+			t.Errorf("%s: assigned to %d, want %d", test.name, got, test.wantID)
+		}
+	}
+}
+
+func TestRoundRobinAssigner(t *testing.T) {
+	rr := NewRoundRobinAssigner()
+	states := map[int]elevator.ElevatorState{1: idle(0), 2: idle(0)}
+
+	steps := []struct {
+		name         string
+		states       map[int]elevator.ElevatorState
+		hallRequests [config.NUM_FLOORS][2]bool
+		want         map[int][config.NUM_FLOORS][2]bool
+	}{
+		{
+			name:         "new requests go to the cars in turn",
+			states:       states,
+			hallRequests: requests(hallRequest{0, up}, hallRequest{2, down}),
+			want:         map[int][config.NUM_FLOORS][2]bool{1: requests(hallRequest{0, up}), 2: requests(hallRequest{2, down})},
+		},
+		{
+			name:         "requests keep their car, and the turn wraps around",
+			states:       states,
+			hallRequests: requests(hallRequest{0, up}, hallRequest{2, down}, hallRequest{3, down}),
+			want: map[int][config.NUM_FLOORS][2]bool{
+				1: requests(hallRequest{0, up}, hallRequest{3, down}),
+				2: requests(hallRequest{2, down}),
+			},
+		},
+		{
+			name:         "the requests of a car that left get the next car",
+			states:       map[int]elevator.ElevatorState{2: idle(0)},
+			hallRequests: requests(hallRequest{0, up}, hallRequest{2, down}, hallRequest{3, down}),
+			want:         map[int][config.NUM_FLOORS][2]bool{2: requests(hallRequest{0, up}, hallRequest{2, down}, hallRequest{3, down})},
+		},
+	}
+	for _, step := range steps {
+		assignments, err := rr.Assign(step.states, step.hallRequests)
+		if err != nil || !reflect.DeepEqual(assignments, step.want) {
+			t.Errorf("%s: got %v, %v, want %v", step.name, assignments, err, step.want)
+		}
+	}
+}
+
+func TestZoningAssigner(t *testing.T) {
+	states := map[int]elevator.ElevatorState{4: idle(3), 7: idle(0)}
+	hallRequests := requests(hallRequest{0, up}, hallRequest{1, down}, hallRequest{2, up}, hallRequest{3, down})
+
+	assignments, err := ZoningAssigner{}.Assign(states, hallRequests)
+	// the lower half of the building goes to the lower id, wherever the cars are
+	want := map[int][config.NUM_FLOORS][2]bool{
+		4: requests(hallRequest{0, up}, hallRequest{1, down}),
+		7: requests(hallRequest{2, up}, hallRequest{3, down}),
+	}
+	if err != nil || !reflect.DeepEqual(assignments, want) {
+		t.Errorf("got %v, %v, want %v", assignments, err, want)
+	}
+}
@@ -0,0 +1,145 @@
+package assigner
+
+import (
+	"elev/config"
+	"elev/elevator"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Candidate is a car that could have served a hall request, with its estimated time-to-serve
+type Candidate struct {
+	NodeID      int
+	TimeToServe time.Duration
+	Reachable   bool // false if the car cannot be expected to serve the request, TimeToServe is then meaningless
+}
+
+// AuditRecord explains the assignment of a single hall request
+type AuditRecord struct {
+	Time       time.Time
+	Floor      int
+	Button     elevator.ButtonType
+	Candidates []Candidate // in order of node id
+	Winner     int         // the node the request was assigned to, -1 if none
+}
+
+func (record AuditRecord) String() string {
+	candidates := make([]string, len(record.Candidates))
+	for i, c := range record.Candidates {
+		if c.Reachable {
+			candidates[i] = fmt.Sprintf("node %d: %v", c.NodeID, c.TimeToServe)
+		} else {
+			candidates[i] = fmt.Sprintf("node %d: unreachable", c.NodeID)
+		}
+	}
+	return fmt.Sprintf("%s floor %d %s -> node %d (%s)",
+		record.Time.Format("15:04:05.000"), record.Floor, record.Button, record.Winner, strings.Join(candidates, ", "))
+}
+
+// AuditLog is a bounded history of assignment decisions. When full, the oldest records are dropped.
+// Safe to use from several goroutines.
+type AuditLog struct {
+	mu      sync.Mutex
+	records []AuditRecord
+	next    int // index of the oldest record once the log is full
+	size    int
+}
+
+func NewAuditLog(size int) *AuditLog {
+	return &AuditLog{records: make([]AuditRecord, 0, size), size: size}
+}
+
+func (auditLog *AuditLog) Add(record AuditRecord) {
+	auditLog.mu.Lock()
+	defer auditLog.mu.Unlock()
+	if auditLog.size <= 0 {
+		return
+	}
+	if len(auditLog.records) < auditLog.size {
+		auditLog.records = append(auditLog.records, record)
+		return
+	}
+	auditLog.records[auditLog.next] = record
+	auditLog.next = (auditLog.next + 1) % auditLog.size
+}
+
+// Records returns the records that match, oldest first. A nil match returns every record.
+func (auditLog *AuditLog) Records(match func(AuditRecord) bool) []AuditRecord {
+	auditLog.mu.Lock()
+	defer auditLog.mu.Unlock()
+
+	var records []AuditRecord
+	for i := 0; i < len(auditLog.records); i++ {
+		record := auditLog.records[(auditLog.next+i)%len(auditLog.records)]
+		if match == nil || match(record) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Dump writes every record to the file at path as a line of JSON
+func (auditLog *AuditLog) Dump(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, record := range auditLog.Records(nil) {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AuditingAssigner records an explanation of the assignments made by another assigner. A request is recorded
+// when it is first assigned and whenever it is given to another car, not every time the assignment is recomputed.
+type AuditingAssigner struct {
+	Inner  Assigner
+	Log    *AuditLog
+	winner map[hallRequest]int // the last recorded winner of every active request
+}
+
+func NewAuditingAssigner(inner Assigner, log *AuditLog) *AuditingAssigner {
+	return &AuditingAssigner{Inner: inner, Log: log, winner: make(map[hallRequest]int)}
+}
+
+func (aa *AuditingAssigner) Assign(elevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+	assignments, err := aa.Inner.Assign(elevStates, hallRequests)
+	if err != nil {
+		return assignments, err
+	}
+
+	now := time.Now()
+	ids := sortedIDs(elevStates)
+	for floor := 0; floor < config.NUM_FLOORS; floor++ {
+		for btn := 0; btn < 2; btn++ {
+			req := hallRequest{floor, btn}
+			if !hallRequests[floor][btn] {
+				delete(aa.winner, req)
+				continue
+			}
+			winner := ownerOf(assignments, floor, btn)
+			if previous, ok := aa.winner[req]; ok && previous == winner {
+				continue
+			}
+			aa.winner[req] = winner
+
+			record := AuditRecord{Time: now, Floor: floor, Button: elevator.ButtonType(btn), Winner: winner}
+			for _, id := range ids {
+				// each car is costed with the rest of its own assignment
+				cost := TimeToServe(elevStates[id], withoutRequest(assignments[id], floor, btn), floor, elevator.ButtonType(btn))
+				record.Candidates = append(record.Candidates, Candidate{NodeID: id, TimeToServe: cost, Reachable: cost != Unreachable})
+			}
+			aa.Log.Add(record)
+		}
+	}
+	return assignments, nil
+}
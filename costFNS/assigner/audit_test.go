@@ -0,0 +1,117 @@
+package assigner
+
+import (
+	"bufio"
+	"elev/config"
+	"elev/elevator"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		added     int
+		wantFloor []int // the floors of the records kept, oldest first
+	}{
+		{"not full", 3, 2, []int{0, 1}},
+		{"full", 3, 3, []int{0, 1, 2}},
+		{"the oldest are dropped", 3, 7, []int{4, 5, 6}},
+		{"size zero keeps nothing", 0, 2, nil},
+	}
+	for _, test := range tests {
+		auditLog := NewAuditLog(test.size)
+		for floor := 0; floor < test.added; floor++ {
+			auditLog.Add(AuditRecord{Floor: floor})
+		}
+		var floors []int
+		for _, record := range auditLog.Records(nil) {
+			floors = append(floors, record.Floor)
+		}
+		if !reflect.DeepEqual(floors, test.wantFloor) {
+			t.Errorf("%s: got records of floors %v, want %v", test.name, floors, test.wantFloor)
+		}
+	}
+
+	auditLog := NewAuditLog(4)
+	for floor := 0; floor < 6; floor++ {
+		auditLog.Add(AuditRecord{Floor: floor % config.NUM_FLOORS, Winner: floor})
+	}
+	matched := auditLog.Records(func(record AuditRecord) bool { return record.Floor == 1 })
+	if len(matched) != 1 || matched[0].Winner != 5 {
+		t.Errorf("got %v, want the record of floor 1 with winner 5", matched)
+	}
+}
+
+func TestAuditingAssigner(t *testing.T) {
+	request := hallRequest{2, up}
+	states := map[int]elevator.ElevatorState{1: idle(0), 2: idle(-1)}
+	toCar := func(id int) fixedAssigner {
+		return fixedAssigner{assignments: map[int][config.NUM_FLOORS][2]bool{id: requests(request), 3 - id: requests()}}
+	}
+
+	auditLog := NewAuditLog(10)
+	aa := NewAuditingAssigner(toCar(1), auditLog)
+	steps := []struct {
+		name         string
+		inner        Assigner
+		hallRequests [config.NUM_FLOORS][2]bool
+		wantRecords  int
+	}{
+		{"a new request is recorded", toCar(1), requests(request), 1},
+		{"the same assignment again is not", toCar(1), requests(request), 1},
+		{"a new winner is", toCar(2), requests(request), 2},
+		{"a served request is forgotten", toCar(2), requests(), 2},
+		{"and recorded again when it comes back", toCar(2), requests(request), 3},
+	}
+	for _, step := range steps {
+		aa.Inner = step.inner
+		if _, err := aa.Assign(states, step.hallRequests); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := len(auditLog.Records(nil)); got != step.wantRecords {
+			t.Errorf("%s: %d records, want %d", step.name, got, step.wantRecords)
+		}
+	}
+
+	record := auditLog.Records(nil)[0]
+	want := []Candidate{
+		{NodeID: 1, TimeToServe: 2 * config.HRA_TRAVEL_DURATION, Reachable: true},
+		{NodeID: 2, TimeToServe: Unreachable, Reachable: false},
+	}
+	if record.Floor != request.floor || record.Button != elevator.ButtonHallUp || record.Winner != 1 || !reflect.DeepEqual(record.Candidates, want) {
+		t.Errorf("got record %v, want winner 1 with candidates %v", record, want)
+	}
+}
+
+func TestAuditLogDump(t *testing.T) {
+	auditLog := NewAuditLog(5)
+	for floor := 0; floor < 3; floor++ {
+		auditLog.Add(AuditRecord{Floor: floor, Winner: floor + 1})
+	}
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := auditLog.Dump(path); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for ; scanner.Scan(); lines++ {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Floor != lines || record.Winner != lines+1 {
+			t.Errorf("line %d: got %+v, %v", lines+1, record, err)
+		}
+	}
+	if lines != 3 {
+		t.Errorf("%d lines, want 3", lines)
+	}
+}
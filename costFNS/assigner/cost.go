@@ -0,0 +1,105 @@
+package assigner
+
+import (
+	"elev/config"
+	"elev/elevator"
+	"math"
+	"time"
+)
+
+// Unreachable is the time-to-serve of a request the car cannot be expected to serve, e.g. when its position is unknown
+const Unreachable = time.Duration(math.MaxInt64)
+
+// TimeToServe estimates how long the car takes to serve the hall request at floor/btn, if it is given the request
+// on top of its cab requests and the hall requests in assigned. The car is simulated with collective control,
+// using the travel and door times of the hall request assigner.
+func TimeToServe(state elevator.ElevatorState, assigned [config.NUM_FLOORS][2]bool, floor int, btn elevator.ButtonType) time.Duration {
+	if state.Floor < 0 || state.Floor >= config.NUM_FLOORS {
+		return Unreachable
+	}
+
+	e := elevator.NewElevator()
+	e.Floor = state.Floor
+	e.Dir = state.Direction
+	e.Behavior = state.Behavior
+	for f := 0; f < config.NUM_FLOORS; f++ {
+		e.Requests[f][elevator.ButtonHallUp] = assigned[f][elevator.ButtonHallUp]
+		e.Requests[f][elevator.ButtonHallDown] = assigned[f][elevator.ButtonHallDown]
+		e.Requests[f][elevator.ButtonCab] = state.CabRequests[f]
+	}
+	e.Requests[floor][btn] = true
+
+	var duration time.Duration
+	switch e.Behavior {
+	case elevator.Moving:
+		next := e.Floor + int(e.Dir)
+		if next < 0 || next >= config.NUM_FLOORS {
+			return Unreachable
+		}
+		e.Floor = next
+		duration += config.HRA_TRAVEL_DURATION / 2
+	case elevator.DoorOpen:
+		// the door is assumed to be half way through its open time
+		e, _ = elevator.RequestsClearAtCurrentFloor(e)
+		if !e.Requests[floor][btn] {
+			return 0
+		}
+		duration += config.HRA_DOOR_OPEN_DURATION / 2
+	}
+
+	// the request is served within two sweeps of the shaft with a stop for every request, so this bound is never reached
+	for step := 0; step < 8*config.NUM_FLOORS; step++ {
+		switch e.Behavior {
+		case elevator.Moving:
+			if !elevator.RequestsShouldStop(e) {
+				e.Floor += int(e.Dir)
+				duration += config.HRA_TRAVEL_DURATION
+				continue
+			}
+			e.Behavior = elevator.DoorOpen
+			e, _ = elevator.RequestsClearAtCurrentFloor(e)
+			if !e.Requests[floor][btn] {
+				return duration
+			}
+			duration += config.HRA_DOOR_OPEN_DURATION
+
+		default:
+			pair := elevator.RequestsChooseDirection(e)
+			e.Dir = pair.Dir
+			e.Behavior = pair.Behavior
+			switch pair.Behavior {
+			case elevator.DoorOpen:
+				e, _ = elevator.RequestsClearAtCurrentFloor(e)
+				if !e.Requests[floor][btn] {
+					return duration
+				}
+				duration += config.HRA_DOOR_OPEN_DURATION
+			case elevator.Moving:
+				e.Floor += int(e.Dir)
+				duration += config.HRA_TRAVEL_DURATION
+			default:
+				return Unreachable
+			}
+		}
+	}
+	return Unreachable
+}
+
+// isMovingTowards tells whether the car is already on its way to the floor, or serving it with the door open
+func isMovingTowards(state elevator.ElevatorState, floor int) bool {
+	switch state.Behavior {
+	case elevator.Moving:
+		return (state.Direction == elevator.DirectionUp && floor > state.Floor) ||
+			(state.Direction == elevator.DirectionDown && floor < state.Floor)
+	case elevator.DoorOpen:
+		return state.Floor == floor
+	default:
+		return false
+	}
+}
+
+// withoutRequest returns the assignment with the request at floor/btn removed
+func withoutRequest(assignment [config.NUM_FLOORS][2]bool, floor int, btn int) [config.NUM_FLOORS][2]bool {
+	assignment[floor][btn] = false
+	return assignment
+}
@@ -0,0 +1,43 @@
+package assigner
+
+import (
+	"elev/config"
+	"elev/elevator"
+	"testing"
+	"time"
+)
+
+func TestTimeToServe(t *testing.T) {
+	travel, door := config.HRA_TRAVEL_DURATION, config.HRA_DOOR_OPEN_DURATION
+	withCab := func(state elevator.ElevatorState, floors ...int) elevator.ElevatorState {
+		for _, floor := range floors {
+			state.CabRequests[floor] = true
+		}
+		return state
+	}
+
+	tests := []struct {
+		name     string
+		state    elevator.ElevatorState
+		assigned [config.NUM_FLOORS][2]bool
+		request  hallRequest
+		want     time.Duration
+	}{
+		{"idle at the floor", idle(1), requests(), hallRequest{1, up}, 0},
+		{"idle two floors away", idle(0), requests(), hallRequest{2, up}, 2 * travel},
+		{"a cab call on the way adds a stop", withCab(idle(0), 1), requests(), hallRequest{2, up}, 2*travel + door},
+		{"a hall call in the other direction on the way does not", idle(0), requests(hallRequest{1, down}), hallRequest{2, up}, 2 * travel},
+		{"moving, half way to the next floor", moving(1, elevator.DirectionUp), requests(), hallRequest{3, down}, travel/2 + travel},
+		{"moving away turns at the last request", withCab(moving(2, elevator.DirectionUp), 3), requests(), hallRequest{1, up},
+			travel/2 + door + 2*travel},
+		{"door open at the floor in the requested direction", doorOpen(1, elevator.DirectionUp), requests(), hallRequest{1, up}, 0},
+		{"door open, half way through the open time", doorOpen(2, elevator.DirectionUp), requests(), hallRequest{0, up}, door/2 + 2*travel},
+		{"unknown position", idle(-1), requests(), hallRequest{0, up}, Unreachable},
+		{"moving out of the shaft", moving(config.NUM_FLOORS-1, elevator.DirectionUp), requests(), hallRequest{0, up}, Unreachable},
+	}
+	for _, test := range tests {
+		if got := TimeToServe(test.state, test.assigned, test.request.floor, elevator.ButtonType(test.request.btn)); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package assigner
+
+import (
+	"elev/Network/messages"
+	"elev/config"
+	"elev/elevator"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DestinationAssigner assigns the destination calls along with the plain hall requests. A call is given to the car
+// with the shortest estimated time to pick up the passenger and bring them to their destination. Calls picked up at
+// the same floor in the same direction share a car, as the car is given them as a single hall request there.
+// A call keeps its car while the car is active, since the passenger has been told which car to take.
+// It is the innermost assigner of the chain, so the hall requests of the calls are kept stable, escalated, guarded
+// and audited like the others. The car a call ends up with is the one given its hall request, see CarsOfCalls.
+type DestinationAssigner struct {
+	Hall Assigner // assigns the hall requests without destination calls
+
+	mu    sync.Mutex // the guard may still be running Assign after its deadline when the next calls are set
+	calls []messages.DestinationCall
+}
+
+func NewDestinationAssigner(hall Assigner) *DestinationAssigner {
+	return &DestinationAssigner{Hall: hall}
+}
+
+// SetCalls sets the destination calls, with their current cars, from the next assignment on
+func (da *DestinationAssigner) SetCalls(calls []messages.DestinationCall) {
+	da.mu.Lock()
+	defer da.mu.Unlock()
+	da.calls = slices.Clone(calls)
+}
+
+// Assign returns the hall assignments, with the hall requests of the destination calls given to their cars
+func (da *DestinationAssigner) Assign(elevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+	da.mu.Lock()
+	destinationCalls := da.calls
+	da.mu.Unlock()
+
+	// the hall requests of the destination calls are left to the destination calls
+	var calls []messages.DestinationCall
+	plainRequests := hallRequests
+	for _, call := range destinationCalls {
+		if hallRequests[call.Origin][call.HallButton()] {
+			calls = append(calls, call)
+			plainRequests[call.Origin][call.HallButton()] = false
+		}
+	}
+	assignments, err := da.Hall.Assign(elevStates, plainRequests)
+	if err != nil || len(calls) == 0 {
+		return assignments, err
+	}
+
+	carOf := make(map[hallRequest]int)
+	for _, call := range calls {
+		req := hallRequest{call.Origin, int(call.HallButton())}
+		if _, active := elevStates[call.Car]; active && call.Car >= 0 {
+			if _, ok := carOf[req]; !ok {
+				carOf[req] = call.Car
+				assign(assignments, call.Car, call.Origin, req.btn)
+			}
+		}
+	}
+
+	ids := sortedIDs(elevStates)
+	for _, call := range calls {
+		req := hallRequest{call.Origin, int(call.HallButton())}
+		if _, ok := carOf[req]; !ok {
+			car := bestCarForTrip(ids, elevStates, assignments, call)
+			carOf[req] = car
+			assign(assignments, car, call.Origin, req.btn)
+		}
+	}
+	return assignments, nil
+}
+
+// CarsOfCalls returns the calls with the cars their hall requests were assigned to, -1 for none
+func CarsOfCalls(calls []messages.DestinationCall, assignments map[int][config.NUM_FLOORS][2]bool) []messages.DestinationCall {
+	assigned := make([]messages.DestinationCall, len(calls))
+	copy(assigned, calls)
+	for i, call := range assigned {
+		assigned[i].Car = ownerOf(assignments, call.Origin, int(call.HallButton()))
+	}
+	return assigned
+}
+
+// bestCarForTrip returns the car that brings the passenger to the destination first. If no car can be
+// expected to serve the call, it goes to the first car.
+func bestCarForTrip(ids []int, elevStates map[int]elevator.ElevatorState,
+	assignments map[int][config.NUM_FLOORS][2]bool, call messages.DestinationCall) int {
+
+	distance := call.Destination - call.Origin
+	if distance < 0 {
+		distance = -distance
+	}
+	trip := time.Duration(distance)*config.HRA_TRAVEL_DURATION + config.HRA_DOOR_OPEN_DURATION
+
+	bestID, bestTime := ids[0], Unreachable
+	for _, id := range ids {
+		pickup := TimeToServe(elevStates[id], assignments[id], call.Origin, call.HallButton())
+		if pickup != Unreachable && pickup+trip < bestTime {
+			bestID, bestTime = id, pickup+trip
+		}
+	}
+	return bestID
+}
@@ -0,0 +1,129 @@
+package assigner
+
+import (
+	"elev/Network/messages"
+	"elev/config"
+	"elev/elevator"
+	"reflect"
+	"testing"
+)
+
+// spyAssigner assigns with the nearest car assigner, and keeps the hall requests it was given
+type spyAssigner struct {
+	hallRequests [config.NUM_FLOORS][2]bool
+}
+
+func (spy *spyAssigner) Assign(elevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+	spy.hallRequests = hallRequests
+	return NearestCarAssigner{}.Assign(elevStates, hallRequests)
+}
+
+func call(origin int, destination int, car int) messages.DestinationCall {
+	return messages.DestinationCall{CallID: uint64(10*origin + destination), Origin: origin, Destination: destination, Car: car}
+}
+
+func TestDestinationAssigner(t *testing.T) {
+	tests := []struct {
+		name         string
+		states       map[int]elevator.ElevatorState
+		calls        []messages.DestinationCall
+		hallRequests [config.NUM_FLOORS][2]bool
+		wantOwners   map[hallRequest]int
+		wantPlain    [config.NUM_FLOORS][2]bool // the hall requests left to the hall assigner
+	}{
+		{
+			name:         "a new call goes to the car that brings the passenger there first",
+			states:       map[int]elevator.ElevatorState{1: idle(0), 2: idle(3)},
+			calls:        []messages.DestinationCall{call(3, 0, -1)},
+			hallRequests: requests(hallRequest{3, down}),
+			wantOwners:   map[hallRequest]int{{3, down}: 2},
+		},
+		{
+			name:         "a call keeps its car while the car is active",
+			states:       map[int]elevator.ElevatorState{1: idle(0), 2: idle(3)},
+			calls:        []messages.DestinationCall{call(3, 0, 1)},
+			hallRequests: requests(hallRequest{3, down}),
+			wantOwners:   map[hallRequest]int{{3, down}: 1},
+		},
+		{
+			name:         "the call of a car that left gets a new car",
+			states:       map[int]elevator.ElevatorState{2: idle(3), 3: idle(0)},
+			calls:        []messages.DestinationCall{call(3, 0, 1)},
+			hallRequests: requests(hallRequest{3, down}),
+			wantOwners:   map[hallRequest]int{{3, down}: 2},
+		},
+		{
+			name:         "calls picked up together share the car of the first",
+			states:       map[int]elevator.ElevatorState{1: idle(0), 2: idle(3)},
+			calls:        []messages.DestinationCall{call(0, 2, 2), call(0, 3, -1)},
+			hallRequests: requests(hallRequest{0, up}),
+			wantOwners:   map[hallRequest]int{{0, up}: 2},
+		},
+		{
+			name:         "plain hall requests go to the hall assigner",
+			states:       map[int]elevator.ElevatorState{1: idle(0), 2: idle(3)},
+			calls:        []messages.DestinationCall{call(3, 0, -1)},
+			hallRequests: requests(hallRequest{3, down}, hallRequest{1, up}),
+			wantOwners:   map[hallRequest]int{{3, down}: 2, {1, up}: 1},
+			wantPlain:    requests(hallRequest{1, up}),
+		},
+		{
+			name:         "a call without its hall request is left out",
+			states:       map[int]elevator.ElevatorState{1: idle(0), 2: idle(3)},
+			calls:        []messages.DestinationCall{call(3, 0, 1)},
+			hallRequests: requests(hallRequest{1, up}),
+			wantOwners:   map[hallRequest]int{{1, up}: 1, {3, down}: -1},
+			wantPlain:    requests(hallRequest{1, up}),
+		},
+	}
+	for _, test := range tests {
+		spy := &spyAssigner{}
+		da := NewDestinationAssigner(spy)
+		da.SetCalls(test.calls)
+		assignments, err := da.Assign(test.states, test.hallRequests)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if err := validateAssignments(test.states, test.hallRequests, assignments); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		for req, wantID := range test.wantOwners {
+			if got := ownerOf(assignments, req.floor, req.btn); got != wantID {
+				t.Errorf("%s: floor %d %s assigned to %d, want %d", test.name, req.floor, elevator.ButtonType(req.btn), got, wantID)
+			}
+		}
+		if spy.hallRequests != test.wantPlain {
+			t.Errorf("%s: the hall assigner got %v, want %v", test.name, spy.hallRequests, test.wantPlain)
+		}
+	}
+}
+
+func TestDestinationAssignerKeepsItsCalls(t *testing.T) {
+	calls := []messages.DestinationCall{call(3, 0, 1)}
+	da := NewDestinationAssigner(NearestCarAssigner{})
+	da.SetCalls(calls)
+	// the caller changing its slice afterwards does not change the calls assigned
+	calls[0] = call(0, 3, 2)
+
+	assignments, err := da.Assign(map[int]elevator.ElevatorState{1: idle(0), 2: idle(3)}, requests(hallRequest{3, down}))
+	if err != nil || ownerOf(assignments, 3, down) != 1 {
+		t.Errorf("got %v, %v, want floor 3 down assigned to 1", assignments, err)
+	}
+}
+
+func TestCarsOfCalls(t *testing.T) {
+	calls := []messages.DestinationCall{call(0, 2, -1), call(3, 1, 1), call(1, 2, 2)}
+	assignments := map[int][config.NUM_FLOORS][2]bool{
+		1: requests(hallRequest{0, up}),
+		2: requests(hallRequest{3, down}),
+	}
+
+	got := CarsOfCalls(calls, assignments)
+	want := []messages.DestinationCall{call(0, 2, 1), call(3, 1, 2), call(1, 2, -1)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if calls[0].Car != -1 {
+		t.Error("the calls given were changed")
+	}
+}
@@ -0,0 +1,72 @@
+package assigner
+
+import (
+	"elev/config"
+	"elev/elevator"
+	"fmt"
+)
+
+// EscalatingAssigner overrides another assigner for the hall requests that have waited too long. An escalated
+// request goes to the nearest available car: the car that would serve it first if it had nothing but its cab calls,
+// so its other hall requests do not count against it. It keeps that car until it is served or the car leaves.
+type EscalatingAssigner struct {
+	Inner Assigner
+
+	escalated   [config.NUM_FLOORS][2]bool
+	escalatedTo map[hallRequest]int // the car each escalated request was forced to
+}
+
+func NewEscalatingAssigner(inner Assigner) *EscalatingAssigner {
+	return &EscalatingAssigner{Inner: inner, escalatedTo: make(map[hallRequest]int)}
+}
+
+// Escalate sets the hall requests to escalate from the next assignment on
+func (ea *EscalatingAssigner) Escalate(escalated [config.NUM_FLOORS][2]bool) {
+	ea.escalated = escalated
+}
+
+func (ea *EscalatingAssigner) Assign(elevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+	assignments, err := ea.Inner.Assign(elevStates, hallRequests)
+	if err != nil {
+		return assignments, err
+	}
+
+	ids := sortedIDs(elevStates)
+	for floor := 0; floor < config.NUM_FLOORS; floor++ {
+		for btn := 0; btn < 2; btn++ {
+			req := hallRequest{floor, btn}
+			if !hallRequests[floor][btn] || !ea.escalated[floor][btn] {
+				delete(ea.escalatedTo, req)
+				continue
+			}
+
+			id, ok := ea.escalatedTo[req]
+			if _, active := elevStates[id]; !ok || !active {
+				id = nearestAvailableCar(ids, elevStates, floor, elevator.ButtonType(btn))
+				if id == -1 {
+					// no car can be expected to serve it, leave it to the inner assigner
+					continue
+				}
+				ea.escalatedTo[req] = id
+				fmt.Printf("Hall request at floor %d %s escalated to node %d\n", floor, elevator.ButtonType(btn), id)
+			}
+
+			if owner := ownerOf(assignments, floor, btn); owner != -1 {
+				assignments[owner] = withoutRequest(assignments[owner], floor, btn)
+			}
+			assign(assignments, id, floor, btn)
+		}
+	}
+	return assignments, nil
+}
+
+// nearestAvailableCar returns the car that serves the request first counting only its cab calls, or -1 if none can
+func nearestAvailableCar(ids []int, elevStates map[int]elevator.ElevatorState, floor int, btn elevator.ButtonType) int {
+	bestID, bestTime := -1, Unreachable
+	for _, id := range ids {
+		if t := TimeToServe(elevStates[id], [config.NUM_FLOORS][2]bool{}, floor, btn); t < bestTime {
+			bestID, bestTime = id, t
+		}
+	}
+	return bestID
+}
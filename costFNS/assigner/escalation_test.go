@@ -0,0 +1,83 @@
+package assigner
+
+import (
+	"elev/config"
+	"elev/elevator"
+	"testing"
+)
+
+func TestEscalatingAssigner(t *testing.T) {
+	request := hallRequest{2, up}
+	// the inner assigner gives the request to car 2, and car 1 the others
+	inner := fixedAssigner{assignments: map[int][config.NUM_FLOORS][2]bool{
+		1: requests(hallRequest{0, up}, hallRequest{3, down}),
+		2: requests(request),
+	}}
+	hallRequests := requests(request, hallRequest{0, up}, hallRequest{3, down})
+
+	tests := []struct {
+		name      string
+		states    map[int]elevator.ElevatorState
+		escalated [config.NUM_FLOORS][2]bool
+		wantID    int
+	}{
+		{
+			name:   "a request that is not escalated keeps the car of the inner assigner",
+			states: map[int]elevator.ElevatorState{1: idle(2), 2: idle(0)},
+			wantID: 2,
+		},
+		{
+			name:      "an escalated request overrides it with the nearest car, its other hall requests not counted",
+			states:    map[int]elevator.ElevatorState{1: idle(2), 2: idle(0)},
+			escalated: requests(request),
+			wantID:    1,
+		},
+		{
+			name:      "an escalated request no car can serve is left to the inner assigner",
+			states:    map[int]elevator.ElevatorState{1: idle(-1), 2: idle(-1)},
+			escalated: requests(request),
+			wantID:    2,
+		},
+	}
+	for _, test := range tests {
+		ea := NewEscalatingAssigner(inner)
+		ea.Escalate(test.escalated)
+		assignments, err := ea.Assign(test.states, hallRequests)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if err := validateAssignments(test.states, hallRequests, assignments); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if got := ownerOf(assignments, request.floor, request.btn); got != test.wantID {
+			t.Errorf("%s: assigned to %d, want %d", test.name, got, test.wantID)
+		}
+	}
+}
+
+func TestEscalatingAssignerKeepsTheCar(t *testing.T) {
+	request := hallRequest{2, up}
+	ea := NewEscalatingAssigner(fixedAssigner{assignments: map[int][config.NUM_FLOORS][2]bool{
+		1: requests(), 2: requests(), 3: requests(request),
+	}})
+	ea.Escalate(requests(request))
+
+	steps := []struct {
+		name   string
+		states map[int]elevator.ElevatorState
+		wantID int
+	}{
+		{"escalated to the nearest car", map[int]elevator.ElevatorState{1: idle(1), 2: idle(0), 3: idle(0)}, 1},
+		{"kept while another car comes nearer", map[int]elevator.ElevatorState{1: idle(1), 2: idle(2), 3: idle(0)}, 1},
+		{"escalated again when the car leaves", map[int]elevator.ElevatorState{2: idle(2), 3: idle(0)}, 2},
+	}
+	for _, step := range steps {
+		assignments, err := ea.Assign(step.states, requests(request))
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := ownerOf(assignments, request.floor, request.btn); got != step.wantID {
+			t.Errorf("%s: assigned to %d, want %d", step.name, got, step.wantID)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package assigner
+
+import (
+	"elev/config"
+	"elev/elevator"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// GuardedAssigner runs another assigner under a deadline and checks its output. If the assigner fails, panics,
+// does not answer in time or leaves a hall request unassigned, the requests are assigned by Fallback instead
+// and an alarm is raised. The alarm is cleared on the first good assignment.
+type GuardedAssigner struct {
+	Inner    Assigner
+	Fallback Assigner
+	Timeout  time.Duration
+
+	busy chan struct{} // holds a token while Inner is running, also after it has missed the deadline
+
+	mu       sync.Mutex // the status may be read by the operator console
+	alarm    bool
+	since    time.Time // when the alarm was raised
+	lastErr  error     // the last failure of Inner
+	Failures int       // assignments made by the fallback
+}
+
+// GuardStatus is the alarm state of a GuardedAssigner
+type GuardStatus struct {
+	Alarm    bool
+	Since    time.Time // when the alarm was raised, if it is active
+	LastErr  error     // the last failure of the assigner, nil if it never failed
+	Failures int       // assignments made by the fallback
+}
+
+func (status GuardStatus) String() string {
+	if status.Alarm {
+		return fmt.Sprintf("ALARM since %s: %v, %d assignments made by the fallback",
+			status.Since.Format("15:04:05.000"), status.LastErr, status.Failures)
+	}
+	if status.LastErr != nil {
+		return fmt.Sprintf("ok, %d assignments made by the fallback, the last after: %v", status.Failures, status.LastErr)
+	}
+	return "ok, no failures"
+}
+
+func NewGuardedAssigner(inner Assigner, fallback Assigner, timeout time.Duration) *GuardedAssigner {
+	return &GuardedAssigner{Inner: inner, Fallback: fallback, Timeout: timeout, busy: make(chan struct{}, 1)}
+}
+
+func (ga *GuardedAssigner) Assign(elevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+	if len(elevStates) == 0 {
+		return nil, errNoElevators
+	}
+
+	assignments, err := ga.assignWithDeadline(elevStates, hallRequests)
+	if err == nil {
+		err = validateAssignments(elevStates, hallRequests, assignments)
+	}
+	ga.mu.Lock()
+	if err == nil {
+		if ga.alarm {
+			ga.alarm = false
+			fmt.Printf("Hall assigner alarm cleared after %d failures\n", ga.Failures)
+		}
+		ga.mu.Unlock()
+		return assignments, nil
+	}
+
+	ga.Failures++
+	ga.lastErr = err
+	if !ga.alarm {
+		ga.alarm, ga.since = true, time.Now()
+		fmt.Printf("ALARM: hall assigner failed: %v, assigning with the fallback until it recovers\n", err)
+	}
+	ga.mu.Unlock()
+	return ga.Fallback.Assign(elevStates, hallRequests)
+}
+
+// Status returns the alarm state, for the operator
+func (ga *GuardedAssigner) Status() GuardStatus {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+	status := GuardStatus{Alarm: ga.alarm, LastErr: ga.lastErr, Failures: ga.Failures}
+	if ga.alarm {
+		status.Since = ga.since
+	}
+	return status
+}
+
+type assignResult struct {
+	assignments map[int][config.NUM_FLOORS][2]bool
+	err         error
+}
+
+func (ga *GuardedAssigner) assignWithDeadline(elevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+	select {
+	case ga.busy <- struct{}{}:
+	default:
+		return nil, errors.New("the previous assignment is still running")
+	}
+
+	// the inner assigner gets its own copy of the states, as it may still be running after the deadline
+	states := make(map[int]elevator.ElevatorState, len(elevStates))
+	for id, state := range elevStates {
+		states[id] = state
+	}
+
+	inner := ga.Inner
+	done := make(chan assignResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- assignResult{err: fmt.Errorf("panic: %v", r)}
+			}
+			<-ga.busy
+		}()
+		assignments, err := inner.Assign(states, hallRequests)
+		done <- assignResult{assignments, err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.assignments, result.err
+	case <-time.After(ga.Timeout):
+		return nil, fmt.Errorf("no answer within %v", ga.Timeout)
+	}
+}
+
+// validateAssignments checks that every hall request is assigned to exactly one of the elevators, and nothing else is
+func validateAssignments(elevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool,
+	assignments map[int][config.NUM_FLOORS][2]bool) error {
+
+	for id := range assignments {
+		if _, ok := elevStates[id]; !ok {
+			return fmt.Errorf("assignment for unknown node %d", id)
+		}
+	}
+	for floor := 0; floor < config.NUM_FLOORS; floor++ {
+		for btn := 0; btn < 2; btn++ {
+			owners := 0
+			for _, assignment := range assignments {
+				if assignment[floor][btn] {
+					owners++
+				}
+			}
+			switch {
+			case hallRequests[floor][btn] && owners != 1:
+				return fmt.Errorf("hall request at floor %d %s assigned to %d cars", floor, elevator.ButtonType(btn), owners)
+			case !hallRequests[floor][btn] && owners != 0:
+				return fmt.Errorf("floor %d %s assigned without a hall request", floor, elevator.ButtonType(btn))
+			}
+		}
+	}
+	return nil
+}
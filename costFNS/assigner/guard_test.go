@@ -0,0 +1,139 @@
+package assigner
+
+import (
+	"elev/config"
+	"elev/elevator"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// assignFunc is an assigner made of a function
+type assignFunc func(map[int]elevator.ElevatorState, [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error)
+
+func (f assignFunc) Assign(elevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+	return f(elevStates, hallRequests)
+}
+
+// hangingAssigner does not answer until it is released
+func hangingAssigner(release <-chan struct{}) assignFunc {
+	return func(map[int]elevator.ElevatorState, [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+		<-release
+		return nil, errors.New("released")
+	}
+}
+
+const testTimeout = 20 * time.Millisecond
+
+var (
+	guardStates       = map[int]elevator.ElevatorState{1: idle(0), 2: idle(3)}
+	guardHallRequests = requests(hallRequest{1, up}, hallRequest{2, down})
+	goodAssignments   = map[int][config.NUM_FLOORS][2]bool{1: requests(hallRequest{1, up}), 2: requests(hallRequest{2, down})}
+	// the fallback is told apart by giving every request to car 2
+	fallbackAssignments = map[int][config.NUM_FLOORS][2]bool{1: requests(), 2: guardHallRequests}
+)
+
+func TestGuardedAssigner(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	tests := []struct {
+		name      string
+		inner     Assigner
+		wantAlarm bool
+	}{
+		{"a good assignment is used", fixedAssigner{assignments: goodAssignments}, false},
+		{"an error", fixedAssigner{err: errors.New("failed")}, true},
+		{"a panic", assignFunc(func(map[int]elevator.ElevatorState, [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+			panic("assigner bug")
+		}), true},
+		{"no answer within the deadline", hangingAssigner(release), true},
+		{"a request left out", fixedAssigner{assignments: map[int][config.NUM_FLOORS][2]bool{
+			1: requests(hallRequest{1, up}), 2: requests(),
+		}}, true},
+		{"a request given to two cars", fixedAssigner{assignments: map[int][config.NUM_FLOORS][2]bool{
+			1: guardHallRequests, 2: requests(hallRequest{2, down}),
+		}}, true},
+		{"a request assigned that nobody made", fixedAssigner{assignments: map[int][config.NUM_FLOORS][2]bool{
+			1: requests(hallRequest{1, up}, hallRequest{0, up}), 2: requests(hallRequest{2, down}),
+		}}, true},
+		{"an unknown id", fixedAssigner{assignments: map[int][config.NUM_FLOORS][2]bool{
+			1: requests(hallRequest{1, up}), 2: requests(hallRequest{2, down}), 3: requests(),
+		}}, true},
+	}
+	for _, test := range tests {
+		ga := NewGuardedAssigner(test.inner, fixedAssigner{assignments: fallbackAssignments}, testTimeout)
+		start := time.Now()
+		assignments, err := ga.Assign(guardStates, guardHallRequests)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if elapsed := time.Since(start); elapsed > 10*testTimeout {
+			t.Errorf("%s: took %v with a deadline of %v", test.name, elapsed, testTimeout)
+		}
+
+		want, wantFailures := goodAssignments, 0
+		if test.wantAlarm {
+			want, wantFailures = fallbackAssignments, 1
+		}
+		if !reflect.DeepEqual(assignments, want) {
+			t.Errorf("%s: got %v, want %v", test.name, assignments, want)
+		}
+		status := ga.Status()
+		if status.Alarm != test.wantAlarm || status.Failures != wantFailures || (status.LastErr != nil) != test.wantAlarm {
+			t.Errorf("%s: got status %v", test.name, status)
+		}
+	}
+}
+
+func TestGuardedAssignerRecovers(t *testing.T) {
+	release := make(chan struct{})
+	hanging := hangingAssigner(release)
+	ga := NewGuardedAssigner(hanging, fixedAssigner{assignments: fallbackAssignments}, testTimeout)
+
+	steps := []struct {
+		name         string
+		inner        Assigner
+		release      bool // release the hanging assigner before the step
+		wantAlarm    bool
+		wantFailures int
+	}{
+		{"the assigner hangs", hanging, false, true, 1},
+		{"it is not run again while it still hangs", fixedAssigner{assignments: goodAssignments}, false, true, 2},
+		{"the alarm is cleared on the first good assignment once it returns", fixedAssigner{assignments: goodAssignments}, true, false, 2},
+	}
+	for _, step := range steps {
+		if step.release {
+			close(release)
+			// wait for the hanging run to give back its token
+			ga.busy <- struct{}{}
+			<-ga.busy
+		}
+		ga.Inner = step.inner
+		assignments, err := ga.Assign(guardStates, guardHallRequests)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		want := goodAssignments
+		if step.wantAlarm {
+			want = fallbackAssignments
+		}
+		if !reflect.DeepEqual(assignments, want) {
+			t.Errorf("%s: got %v, want %v", step.name, assignments, want)
+		}
+		if status := ga.Status(); status.Alarm != step.wantAlarm || status.Failures != step.wantFailures {
+			t.Errorf("%s: got status %v", step.name, status)
+		}
+	}
+}
+
+func TestGuardedAssignerWithoutElevators(t *testing.T) {
+	ga := NewGuardedAssigner(NearestCarAssigner{}, NearestCarAssigner{}, testTimeout)
+	if _, err := ga.Assign(map[int]elevator.ElevatorState{}, guardHallRequests); !errors.Is(err, errNoElevators) {
+		t.Errorf("got %v, want %v", err, errNoElevators)
+	}
+	if ga.Status().Alarm {
+		t.Error("no elevators raised the alarm")
+	}
+}
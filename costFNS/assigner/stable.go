@@ -0,0 +1,76 @@
+package assigner
+
+import (
+	"elev/config"
+	"elev/elevator"
+	"fmt"
+	"time"
+)
+
+// StableAssigner adds hysteresis to another assigner. A hall request keeps the car it was given last time
+// if that car is already on its way to it, unless the new car would serve it faster by more than Threshold.
+// This stops cars from swapping calls they are driving towards every time the requests are redistributed.
+type StableAssigner struct {
+	Inner     Assigner
+	Threshold time.Duration
+
+	previous      map[hallRequest]int // the car each hall request was given last time
+	Reassignments int                 // requests moved from a car on its way to them
+	Kept          int                 // requests kept by a car on its way to them, against the inner assigner
+}
+
+func NewStableAssigner(inner Assigner, threshold time.Duration) *StableAssigner {
+	return &StableAssigner{Inner: inner, Threshold: threshold, previous: make(map[hallRequest]int)}
+}
+
+func (sa *StableAssigner) Assign(elevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) (map[int][config.NUM_FLOORS][2]bool, error) {
+	assignments, err := sa.Inner.Assign(elevStates, hallRequests)
+	if err != nil {
+		return assignments, err
+	}
+
+	current := make(map[hallRequest]int)
+	for floor := 0; floor < config.NUM_FLOORS; floor++ {
+		for btn := 0; btn < 2; btn++ {
+			if !hallRequests[floor][btn] {
+				continue
+			}
+			req := hallRequest{floor, btn}
+			newID := ownerOf(assignments, floor, btn)
+			current[req] = newID
+
+			prevID, ok := sa.previous[req]
+			prevState, active := elevStates[prevID]
+			if !ok || !active || newID == -1 || prevID == newID || !isMovingTowards(prevState, floor) {
+				continue
+			}
+
+			// compare the two cars with the rest of their new assignments
+			prevCost := TimeToServe(prevState, withoutRequest(assignments[prevID], floor, btn), floor, elevator.ButtonType(btn))
+			newCost := TimeToServe(elevStates[newID], withoutRequest(assignments[newID], floor, btn), floor, elevator.ButtonType(btn))
+			if prevCost != Unreachable && (newCost == Unreachable || prevCost-newCost <= sa.Threshold) {
+				assignments[newID] = withoutRequest(assignments[newID], floor, btn)
+				assign(assignments, prevID, floor, btn)
+				current[req] = prevID
+				sa.Kept++
+				continue
+			}
+
+			sa.Reassignments++
+			fmt.Printf("Hall request at floor %d %s reassigned from node %d to node %d, %v faster (%d reassigned, %d kept so far)\n",
+				floor, elevator.ButtonType(btn), prevID, newID, prevCost-newCost, sa.Reassignments, sa.Kept)
+		}
+	}
+	sa.previous = current
+	return assignments, nil
+}
+
+// ownerOf returns the id of the car assigned the request at floor/btn, or -1 if there is none
+func ownerOf(assignments map[int][config.NUM_FLOORS][2]bool, floor int, btn int) int {
+	for id, assignment := range assignments {
+		if assignment[floor][btn] {
+			return id
+		}
+	}
+	return -1
+}
@@ -0,0 +1,80 @@
+package assigner
+
+import (
+	"elev/config"
+	"elev/elevator"
+	"testing"
+	"time"
+)
+
+func TestStableAssigner(t *testing.T) {
+	const threshold = 3 * time.Second
+	request := hallRequest{3, down}
+	toCar := func(id int) fixedAssigner {
+		return fixedAssigner{assignments: map[int][config.NUM_FLOORS][2]bool{id: requests(request), 3 - id: requests()}}
+	}
+
+	tests := []struct {
+		name              string
+		first             map[int]elevator.ElevatorState // car 1 is given the request
+		then              map[int]elevator.ElevatorState // the inner assigner moves it to car 2
+		wantID            int
+		wantKept          int
+		wantReassignments int
+	}{
+		{
+			// car 1 is 3.75 s away, car 2 2.5 s
+			name:     "a car on its way keeps the request below the threshold",
+			first:    map[int]elevator.ElevatorState{1: moving(1, elevator.DirectionUp), 2: idle(0)},
+			then:     map[int]elevator.ElevatorState{1: moving(1, elevator.DirectionUp), 2: idle(2)},
+			wantID:   1,
+			wantKept: 1,
+		},
+		{
+			// car 2 is already there
+			name:              "a car on its way loses the request above the threshold",
+			first:             map[int]elevator.ElevatorState{1: moving(1, elevator.DirectionUp), 2: idle(0)},
+			then:              map[int]elevator.ElevatorState{1: moving(1, elevator.DirectionUp), 2: idle(3)},
+			wantID:            2,
+			wantReassignments: 1,
+		},
+		{
+			name:   "a car not on its way does not keep the request",
+			first:  map[int]elevator.ElevatorState{1: idle(2), 2: idle(0)},
+			then:   map[int]elevator.ElevatorState{1: idle(2), 2: idle(1)},
+			wantID: 2,
+		},
+		{
+			name:   "a car moving away does not keep the request",
+			first:  map[int]elevator.ElevatorState{1: moving(2, elevator.DirectionUp), 2: idle(0)},
+			then:   map[int]elevator.ElevatorState{1: moving(1, elevator.DirectionDown), 2: idle(1)},
+			wantID: 2,
+		},
+		{
+			name:   "a car that left does not keep the request",
+			first:  map[int]elevator.ElevatorState{1: moving(1, elevator.DirectionUp), 2: idle(0)},
+			then:   map[int]elevator.ElevatorState{2: idle(2)},
+			wantID: 2,
+		},
+	}
+	for _, test := range tests {
+		sa := NewStableAssigner(toCar(1), threshold)
+		if _, err := sa.Assign(test.first, requests(request)); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		sa.Inner = toCar(2)
+		assignments, err := sa.Assign(test.then, requests(request))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if err := validateAssignments(map[int]elevator.ElevatorState{1: {}, 2: {}}, requests(request), assignments); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if got := ownerOf(assignments, request.floor, request.btn); got != test.wantID {
+			t.Errorf("%s: assigned to %d, want %d", test.name, got, test.wantID)
+		}
+		if sa.Kept != test.wantKept || sa.Reassignments != test.wantReassignments {
+			t.Errorf("%s: %d kept, %d reassigned, want %d and %d", test.name, sa.Kept, sa.Reassignments, test.wantKept, test.wantReassignments)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"elev/elevator"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 	"runtime"
 	"strconv"
@@ -26,7 +27,34 @@ type HRAInput struct {
 	States       map[string]HRAElevState    `json:"states"`
 }
 
-func HRAalgorithm(allElevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) map[int][config.NUM_FLOORS][2]bool {
+// HRACorpusEntry is a line of a corpus file: a recorded input, and optionally the output expected for it
+type HRACorpusEntry struct {
+	Input    HRAInput                              `json:"input"`
+	Expected map[string][config.NUM_FLOORS][2]bool `json:"expected,omitempty"`
+	Source   string                                `json:"source,omitempty"` // where the expected output comes from
+}
+
+// SourceExecutable marks an expected output recorded from the hall request assigner executable
+const SourceExecutable = "executable"
+
+// HRAassign runs the native hall request assigner on the input and returns the assignments by node id.
+// The input is recorded in config.HRA_RECORD_FILE if it is set.
+func HRAassign(input HRAInput) (map[int][config.NUM_FLOORS][2]bool, error) {
+	if config.HRA_RECORD_FILE != "" {
+		if err := RecordHRAInput(config.HRA_RECORD_FILE, input); err != nil {
+			fmt.Println("Error recording hall request assigner input: ", err)
+		}
+	}
+
+	output, err := OptimalHallRequests(input, DefaultHRAConfig())
+	if err != nil {
+		return nil, err
+	}
+	return formatHRAOutput(output), nil
+}
+
+// MakeHRAInput converts the elevator states to the input format of the hall request assigner
+func MakeHRAInput(allElevStates map[int]elevator.ElevatorState, hallRequests [config.NUM_FLOORS][2]bool) HRAInput {
 	allElevStatesInputFormat := make(map[string]HRAElevState)
 
 	for id, nodeState := range allElevStates {
@@ -37,49 +65,84 @@ func HRAalgorithm(allElevStates map[int]elevator.ElevatorState, hallRequests [co
 			CabRequests: nodeState.CabRequests,
 		}
 	}
-	input := HRAInput{
+	return HRAInput{
 		HallRequests: hallRequests,
 		States:       allElevStatesInputFormat,
 	}
-	// fmt.Printf("HRAalgorithm input: %v\n", input)
-
-	hraExecutable := ""
-	switch runtime.GOOS {
-	case "linux":
-		hraExecutable = "hall_request_assigner"
-	case "windows":
-		hraExecutable = "hall_request_assigner.exe"
-	default:
-		panic("OS not supported")
-	}
+}
 
+// HRAexecutable runs the hall request assigner executable on the input, with its default parameters.
+// It is the reference the native assigner is verified against. A Windows executable is run with wine on other systems.
+func HRAexecutable(executable string, input HRAInput) (map[string][config.NUM_FLOORS][2]bool, error) {
 	jsonBytes, err := json.Marshal(input)
 	if err != nil {
-		fmt.Println("json.Marshal error: ", err)
-		return nil
+		return nil, err
 	}
-	// fmt.Printf("jsonBytes: %v\n", string(jsonBytes))
-	ret, err := exec.Command("costFNS/hallRequestAssigner/"+hraExecutable, "-i", string(jsonBytes)).CombinedOutput()
-	if err != nil {
-		fmt.Println("exec.Command error: ", err)
-		fmt.Println(string(ret))
-		return nil
+	cmd := exec.Command(executable, "-i", string(jsonBytes))
+	if needsWine(executable) {
+		cmd = exec.Command("wine", executable, "-i", string(jsonBytes))
+	}
+	// only stdout, wine may warn on stderr
+	ret, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return nil, fmt.Errorf("%w: %s", err, string(exitErr.Stderr))
+	} else if err != nil {
+		return nil, err
 	}
 
-	HRAoutput := new(map[string][config.NUM_FLOORS][2]bool)
-	err = json.Unmarshal(ret, &HRAoutput)
+	var output map[string][config.NUM_FLOORS][2]bool
+	if err := json.Unmarshal(ret, &output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DefaultHRAExecutable is the path of the executable, relative to the repository root
+func DefaultHRAExecutable() string {
+	return "costFNS/hallRequestAssigner/hall_request_assigner.exe"
+}
+
+// CheckHRAexecutable returns an error if the executable cannot be run here
+func CheckHRAexecutable(executable string) error {
+	if _, err := os.Stat(executable); err != nil {
+		return err
+	}
+	if needsWine(executable) {
+		if _, err := exec.LookPath("wine"); err != nil {
+			return fmt.Errorf("%s needs wine to run on %s: %w", executable, runtime.GOOS, err)
+		}
+	}
+	return nil
+}
+
+func needsWine(executable string) bool {
+	return runtime.GOOS != "windows" && strings.HasSuffix(executable, ".exe")
+}
+
+// RecordHRAInput appends the input as a corpus entry to the file at path
+func RecordHRAInput(path string, input HRAInput) error {
+	jsonBytes, err := json.Marshal(HRACorpusEntry{Input: input})
 	if err != nil {
-		fmt.Println("json.Unmarshal error: ", err)
-		return nil
+		return err
 	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(jsonBytes, '\n'))
+	return err
+}
 
+// formatHRAOutput converts the ids of the output back to node ids
+func formatHRAOutput(output map[string][config.NUM_FLOORS][2]bool) map[int][config.NUM_FLOORS][2]bool {
 	HRAoutputFormatting := make(map[int][config.NUM_FLOORS][2]bool) // Convert map[string][config.NUM_FLOORS][2]bool to map[int][config.NUM_FLOORS][2]bool
-	for id, output := range *HRAoutput {
+	for id, assignment := range output {
 		id, err := strconv.Atoi(id)
 		if err != nil {
 			fmt.Println("Error: ", err)
 		}
-		HRAoutputFormatting[id] = output
+		HRAoutputFormatting[id] = assignment
 	}
 	return HRAoutputFormatting // map[int][config.NUM_FLOORS][2]bool
 }
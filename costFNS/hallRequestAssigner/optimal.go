@@ -0,0 +1,428 @@
+package hallRequestAssigner
+
+import (
+	"elev/config"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Native port of optimal_hall_requests.d from the hall request assigner executable.
+// Every elevator is simulated forward in time, and each unassigned hall request goes to the elevator
+// that would reach it first. With the same parameters it gives the same assignments as the executable.
+
+type ClearRequestType string
+
+const (
+	ClearAll    ClearRequestType = "all"    // stopping at a floor clears every request there
+	ClearInDirn ClearRequestType = "inDirn" // stopping at a floor only clears the requests in the direction of travel
+)
+
+// HRAConfig holds the parameters of the executable's command line
+type HRAConfig struct {
+	TravelDuration   time.Duration
+	DoorOpenDuration time.Duration
+	ClearRequestType ClearRequestType
+}
+
+// DefaultHRAConfig returns the parameters used for hall assignment, which are the defaults of the executable
+func DefaultHRAConfig() HRAConfig {
+	return HRAConfig{
+		TravelDuration:   config.HRA_TRAVEL_DURATION,
+		DoorOpenDuration: config.HRA_DOOR_OPEN_DURATION,
+		ClearRequestType: ClearInDirn,
+	}
+}
+
+const (
+	hraHallUp = iota
+	hraHallDown
+	hraCab
+)
+
+type hraDirection int
+
+const (
+	hraDown hraDirection = -1
+	hraStop hraDirection = 0
+	hraUp   hraDirection = 1
+)
+
+type hraRequest struct {
+	active     bool
+	assignedTo string
+}
+
+func (req hraRequest) isUnassigned() bool {
+	return req.active && req.assignedTo == ""
+}
+
+type hraElevator struct {
+	behavior    string
+	floor       int
+	direction   hraDirection
+	cabRequests [config.NUM_FLOORS]bool
+}
+
+type hraSimState struct {
+	id   string
+	elev hraElevator
+	time time.Duration
+}
+
+// hraElevatorWithRequests is an elevator together with the requests it is simulated to serve
+type hraElevatorWithRequests struct {
+	floor     int
+	direction hraDirection
+	requests  [config.NUM_FLOORS][3]bool
+}
+
+// OptimalHallRequests assigns every active hall request to one of the elevators.
+// The output has an entry for every elevator, with the same ids as the input.
+func OptimalHallRequests(input HRAInput, cfg HRAConfig) (map[string][config.NUM_FLOORS][2]bool, error) {
+	states, err := initialStates(input.States)
+	if err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return nil, errors.New("no elevators")
+	}
+
+	var reqs [config.NUM_FLOORS][2]hraRequest
+	for floor := range reqs {
+		for btn := range reqs[floor] {
+			reqs[floor][btn].active = input.HallRequests[floor][btn]
+		}
+	}
+
+	for i := range states {
+		performInitialMove(&states[i], &reqs, cfg)
+	}
+
+	for {
+		// the states never have equal times, see initialStates, so the order is well defined
+		sort.Slice(states, func(i, j int) bool { return states[i].time < states[j].time })
+
+		done := !anyUnassigned(reqs)
+		if unvisitedAreImmediatelyAssignable(reqs, states) {
+			assignImmediate(&reqs, states, cfg)
+			done = true
+		}
+		if done {
+			break
+		}
+
+		performSingleMove(&states[0], &reqs, cfg)
+	}
+
+	output := make(map[string][config.NUM_FLOORS][2]bool, len(input.States))
+	for id := range input.States {
+		output[id] = [config.NUM_FLOORS][2]bool{}
+	}
+	for floor := range reqs {
+		for btn, req := range reqs[floor] {
+			if req.active {
+				assignment := output[req.assignedTo]
+				assignment[floor][btn] = true
+				output[req.assignedTo] = assignment
+			}
+		}
+	}
+	return output, nil
+}
+
+// initialStates validates and converts the input states, sorted by id.
+// Each elevator starts a microsecond after the previous one, which breaks ties in favour of the lowest id.
+func initialStates(input map[string]HRAElevState) ([]hraSimState, error) {
+	ids := make([]string, 0, len(input))
+	for id := range input {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	states := make([]hraSimState, 0, len(ids))
+	for i, id := range ids {
+		elev, err := parseElevState(input[id])
+		if err != nil {
+			return nil, fmt.Errorf("elevator %q: %w", id, err)
+		}
+		states = append(states, hraSimState{id: id, elev: elev, time: time.Duration(i) * time.Microsecond})
+	}
+	return states, nil
+}
+
+func parseElevState(state HRAElevState) (hraElevator, error) {
+	elev := hraElevator{floor: state.Floor, cabRequests: state.CabRequests}
+
+	switch state.Behavior {
+	case "idle", "moving", "doorOpen":
+		elev.behavior = state.Behavior
+	default:
+		return elev, fmt.Errorf("invalid behavior %q", state.Behavior)
+	}
+
+	switch state.Direction {
+	case "up":
+		elev.direction = hraUp
+	case "down":
+		elev.direction = hraDown
+	case "stop":
+		elev.direction = hraStop
+	default:
+		return elev, fmt.Errorf("invalid direction %q", state.Direction)
+	}
+
+	if elev.floor < 0 || elev.floor >= config.NUM_FLOORS {
+		return elev, fmt.Errorf("invalid floor %d", elev.floor)
+	}
+	if elev.behavior == "moving" {
+		if next := elev.floor + int(elev.direction); elev.direction == hraStop || next < 0 || next >= config.NUM_FLOORS {
+			return elev, fmt.Errorf("moving %s from floor %d", state.Direction, elev.floor)
+		}
+	}
+	return elev, nil
+}
+
+func anyUnassigned(reqs [config.NUM_FLOORS][2]hraRequest) bool {
+	for floor := range reqs {
+		for _, req := range reqs[floor] {
+			if req.isUnassigned() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func anyCabRequests(elev hraElevator) bool {
+	for _, cab := range elev.cabRequests {
+		if cab {
+			return true
+		}
+	}
+	return false
+}
+
+// unvisitedAreImmediatelyAssignable is true when every unassigned hall request is at a floor with an elevator
+// that has nothing else to do, so the rest of the simulation can be skipped
+func unvisitedAreImmediatelyAssignable(reqs [config.NUM_FLOORS][2]hraRequest, states []hraSimState) bool {
+	for _, s := range states {
+		if anyCabRequests(s.elev) {
+			return false
+		}
+	}
+
+	for floor := range reqs {
+		if reqs[floor][hraHallUp].active && reqs[floor][hraHallDown].active {
+			return false
+		}
+		for _, req := range reqs[floor] {
+			if !req.isUnassigned() {
+				continue
+			}
+			assignable := false
+			for _, s := range states {
+				if s.elev.floor == floor && !anyCabRequests(s.elev) {
+					assignable = true
+				}
+			}
+			if !assignable {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func assignImmediate(reqs *[config.NUM_FLOORS][2]hraRequest, states []hraSimState, cfg HRAConfig) {
+	for floor := range reqs {
+		for btn := range reqs[floor] {
+			for i := range states {
+				s := &states[i]
+				if reqs[floor][btn].isUnassigned() && s.elev.floor == floor && !anyCabRequests(s.elev) {
+					reqs[floor][btn].assignedTo = s.id
+					s.time += cfg.DoorOpenDuration
+				}
+			}
+		}
+	}
+}
+
+func performInitialMove(s *hraSimState, reqs *[config.NUM_FLOORS][2]hraRequest, cfg HRAConfig) {
+	switch s.elev.behavior {
+	case "doorOpen":
+		s.time += cfg.DoorOpenDuration / 2
+		fallthrough
+	case "idle":
+		for btn := range reqs[s.elev.floor] {
+			if reqs[s.elev.floor][btn].active {
+				reqs[s.elev.floor][btn].assignedTo = s.id
+				s.time += cfg.DoorOpenDuration
+			}
+		}
+	case "moving":
+		s.elev.floor += int(s.elev.direction)
+		s.time += cfg.TravelDuration / 2
+	}
+}
+
+func performSingleMove(s *hraSimState, reqs *[config.NUM_FLOORS][2]hraRequest, cfg HRAConfig) {
+	e := withUnassignedRequests(s.elev, *reqs)
+
+	onClearRequest := func(btn int) {
+		switch btn {
+		case hraHallUp, hraHallDown:
+			reqs[s.elev.floor][btn].assignedTo = s.id
+		case hraCab:
+			s.elev.cabRequests[s.elev.floor] = false
+		}
+	}
+
+	switch s.elev.behavior {
+	case "moving":
+		if e.shouldStop() {
+			s.elev.behavior = "doorOpen"
+			s.time += cfg.DoorOpenDuration
+			e.clearAtCurrentFloor(cfg.ClearRequestType, onClearRequest)
+		} else {
+			s.elev.floor += int(s.elev.direction)
+			s.time += cfg.TravelDuration
+		}
+
+	case "idle", "doorOpen":
+		// like the executable, requests are cleared by the direction the elevator had before choosing
+		s.elev.direction = e.chooseDirection()
+		if s.elev.direction == hraStop {
+			if e.anyRequestsHere() {
+				e.clearAtCurrentFloor(cfg.ClearRequestType, onClearRequest)
+				s.time += cfg.DoorOpenDuration
+				s.elev.behavior = "doorOpen"
+			} else {
+				s.elev.behavior = "idle"
+			}
+		} else {
+			s.elev.behavior = "moving"
+			s.elev.floor += int(s.elev.direction)
+			s.time += cfg.TravelDuration
+		}
+	}
+}
+
+// withUnassignedRequests gives the elevator the hall requests nobody has taken yet, in addition to its cab requests
+func withUnassignedRequests(elev hraElevator, reqs [config.NUM_FLOORS][2]hraRequest) hraElevatorWithRequests {
+	e := hraElevatorWithRequests{floor: elev.floor, direction: elev.direction}
+	for floor := range reqs {
+		e.requests[floor][hraHallUp] = reqs[floor][hraHallUp].isUnassigned()
+		e.requests[floor][hraHallDown] = reqs[floor][hraHallDown].isUnassigned()
+		e.requests[floor][hraCab] = elev.cabRequests[floor]
+	}
+	return e
+}
+
+func (e hraElevatorWithRequests) requestsAbove() bool {
+	for floor := e.floor + 1; floor < config.NUM_FLOORS; floor++ {
+		for _, req := range e.requests[floor] {
+			if req {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (e hraElevatorWithRequests) requestsBelow() bool {
+	for floor := 0; floor < e.floor; floor++ {
+		for _, req := range e.requests[floor] {
+			if req {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (e hraElevatorWithRequests) anyRequestsHere() bool {
+	for _, req := range e.requests[e.floor] {
+		if req {
+			return true
+		}
+	}
+	return false
+}
+
+func (e hraElevatorWithRequests) shouldStop() bool {
+	switch e.direction {
+	case hraUp:
+		return e.requests[e.floor][hraHallUp] || e.requests[e.floor][hraCab] || !e.requestsAbove() ||
+			e.floor == 0 || e.floor == config.NUM_FLOORS-1
+	case hraDown:
+		return e.requests[e.floor][hraHallDown] || e.requests[e.floor][hraCab] || !e.requestsBelow() ||
+			e.floor == 0 || e.floor == config.NUM_FLOORS-1
+	default:
+		return true
+	}
+}
+
+func (e hraElevatorWithRequests) chooseDirection() hraDirection {
+	switch e.direction {
+	case hraUp:
+		switch {
+		case e.requestsAbove():
+			return hraUp
+		case e.anyRequestsHere():
+			return hraStop
+		case e.requestsBelow():
+			return hraDown
+		default:
+			return hraStop
+		}
+	default:
+		switch {
+		case e.requestsBelow():
+			return hraDown
+		case e.anyRequestsHere():
+			return hraStop
+		case e.requestsAbove():
+			return hraUp
+		default:
+			return hraStop
+		}
+	}
+}
+
+// clearAtCurrentFloor reports the requests that stopping at the current floor clears
+func (e hraElevatorWithRequests) clearAtCurrentFloor(clearType ClearRequestType, onClearRequest func(btn int)) {
+	clear := func(btn int) {
+		if e.requests[e.floor][btn] {
+			onClearRequest(btn)
+			e.requests[e.floor][btn] = false
+		}
+	}
+
+	if clearType == ClearAll {
+		for btn := range e.requests[e.floor] {
+			clear(btn)
+		}
+		return
+	}
+
+	clear(hraCab)
+	switch e.direction {
+	case hraUp:
+		if e.requests[e.floor][hraHallUp] {
+			clear(hraHallUp)
+		} else if !e.requestsAbove() {
+			clear(hraHallDown)
+		}
+	case hraDown:
+		if e.requests[e.floor][hraHallDown] {
+			clear(hraHallDown)
+		} else if !e.requestsBelow() {
+			clear(hraHallUp)
+		}
+	default:
+		clear(hraHallUp)
+		clear(hraHallDown)
+	}
+}
@@ -0,0 +1,106 @@
+package hallRequestAssigner
+
+import (
+	"bufio"
+	"elev/config"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func readCorpus(t *testing.T) []HRACorpusEntry {
+	t.Helper()
+	file, err := os.Open("corpus.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var entries []HRACorpusEntry
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry HRACorpusEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line %d: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+// TestOptimalHallRequestsCorpus checks the native assigner against the outputs of the executable recorded in the
+// corpus, so it is verified where the executable cannot be run
+func TestOptimalHallRequestsCorpus(t *testing.T) {
+	cfg := DefaultHRAConfig()
+	checked := 0
+	for i, entry := range readCorpus(t) {
+		if entry.Source != SourceExecutable {
+			if entry.Expected != nil {
+				t.Errorf("entry %d: expected output from %q, only outputs of the executable are recorded", i, entry.Source)
+			}
+			continue
+		}
+		checked++
+
+		output, err := OptimalHallRequests(entry.Input, cfg)
+		if err != nil {
+			t.Errorf("entry %d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(output, entry.Expected) {
+			t.Errorf("entry %d: got %v, expected %v", i, output, entry.Expected)
+		}
+	}
+	if checked == 0 {
+		t.Fatal("no corpus entries with an output of the executable")
+	}
+}
+
+// TestOptimalHallRequestsExecutable checks the native assigner against the executable on every input of the corpus
+func TestOptimalHallRequestsExecutable(t *testing.T) {
+	const executable = "hall_request_assigner.exe"
+	if err := CheckHRAexecutable(executable); err != nil {
+		t.Skipf("cannot run the executable: %v", err)
+	}
+
+	cfg := DefaultHRAConfig()
+	for i, entry := range readCorpus(t) {
+		reference, err := HRAexecutable(executable, entry.Input)
+		if err != nil {
+			t.Fatalf("entry %d: %v", i, err)
+		}
+		output, err := OptimalHallRequests(entry.Input, cfg)
+		if err != nil || !reflect.DeepEqual(output, reference) {
+			t.Errorf("entry %d: got %v %v, executable %v", i, output, err, reference)
+		}
+	}
+}
+
+func TestOptimalHallRequestsInvalidInput(t *testing.T) {
+	var hallRequests [config.NUM_FLOORS][2]bool
+	hallRequests[1][0] = true
+	idle := HRAElevState{Behavior: "idle", Floor: 0, Direction: "stop"}
+
+	tests := []struct {
+		name   string
+		states map[string]HRAElevState
+	}{
+		{"no elevators", map[string]HRAElevState{}},
+		{"unknown behavior", map[string]HRAElevState{"1": {Behavior: "flying", Floor: 0, Direction: "stop"}}},
+		{"unknown direction", map[string]HRAElevState{"1": {Behavior: "idle", Floor: 0, Direction: "sideways"}}},
+		{"floor out of range", map[string]HRAElevState{"1": idle, "2": {Behavior: "idle", Floor: config.NUM_FLOORS, Direction: "stop"}}},
+	}
+	for _, test := range tests {
+		output, err := OptimalHallRequests(HRAInput{HallRequests: hallRequests, States: test.states}, DefaultHRAConfig())
+		if err == nil {
+			t.Errorf("%s: expected an error, got %v", test.name, output)
+		}
+	}
+}
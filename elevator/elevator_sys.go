@@ -3,6 +3,7 @@ package elevator
 import (
 	"elev/config"
 	"fmt"
+	"strings"
 )
 
 type ElevatorBehavior int
@@ -13,6 +14,16 @@ const (
 	Moving
 )
 
+// ServiceMode is the mode of operation of a car. Only cars in NormalService take hall assignments.
+type ServiceMode int
+
+const (
+	NormalService      ServiceMode = iota
+	MaintenanceService             // out of service for maintenance, parked with the doors open
+	RecallService                  // fire recall, nonstop to the recall floor and parked there with the doors open
+	IndependentService             // dedicated to a single user, only cab calls and the door is held open between them
+)
+
 type Elevator struct {
 	Floor           int
 	Dir             MotorDirection
@@ -20,6 +31,9 @@ type Elevator struct {
 	Requests        [config.NUM_FLOORS][config.NUM_BUTTONS]bool
 	HallLightStates [config.NUM_FLOORS][config.NUM_BUTTONS - 1]bool
 	IsObstructed    bool
+	IsStopped       bool // emergency stop is engaged
+	ServiceMode     ServiceMode
+	ParkFloor       int // floor to go to when there are no requests, -1 if none
 }
 
 type ElevatorState struct {
@@ -27,6 +41,41 @@ type ElevatorState struct {
 	Direction   MotorDirection
 	Behavior    ElevatorBehavior
 	CabRequests [config.NUM_FLOORS]bool
+	ServiceMode ServiceMode
+	Health      ElevatorHealth
+}
+
+// ElevatorHealth holds the faults that keep a car from serving hall requests
+type ElevatorHealth struct {
+	Obstructed  bool
+	DoorStuck   bool
+	MotorFault  bool // the car did not reach the next floor in time
+	Maintenance bool
+}
+
+// Healthy returns true if the car can be given hall requests
+func (health ElevatorHealth) Healthy() bool {
+	return !health.Obstructed && !health.DoorStuck && !health.MotorFault && !health.Maintenance
+}
+
+func (health ElevatorHealth) String() string {
+	var faults []string
+	if health.Obstructed {
+		faults = append(faults, "obstructed")
+	}
+	if health.DoorStuck {
+		faults = append(faults, "door stuck")
+	}
+	if health.MotorFault {
+		faults = append(faults, "motor fault")
+	}
+	if health.Maintenance {
+		faults = append(faults, "maintenance")
+	}
+	if len(faults) == 0 {
+		return "healthy"
+	}
+	return strings.Join(faults, ", ")
 }
 
 // String returns a string representation of the ElevatorBehavior
@@ -43,6 +92,21 @@ func (eb ElevatorBehavior) String() string {
 	}
 }
 
+func (mode ServiceMode) String() string {
+	switch mode {
+	case NormalService:
+		return "normal"
+	case MaintenanceService:
+		return "maintenance"
+	case RecallService:
+		return "recall"
+	case IndependentService:
+		return "independent"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(mode))
+	}
+}
+
 func (button ButtonType) String() string {
 	switch button {
 	case ButtonHallUp:
@@ -80,10 +144,11 @@ func GetCabRequestsAsElevState(elev Elevator) [config.NUM_FLOORS]bool {
 
 func NewElevator() Elevator {
 	return Elevator{
-		Behavior: Idle,
-		Floor:    -1,
-		Dir:      DirectionStop,
-		Requests: [config.NUM_FLOORS][config.NUM_BUTTONS]bool{},
+		Behavior:  Idle,
+		Floor:     -1,
+		Dir:       DirectionStop,
+		Requests:  [config.NUM_FLOORS][config.NUM_BUTTONS]bool{},
+		ParkFloor: -1,
 	}
 }
 
@@ -99,6 +164,8 @@ func PrintElevator(e Elevator) {
 	fmt.Printf("Direction: %s\n", dir)
 	fmt.Printf("Behavior: %s\n", behavior)
 	fmt.Printf("Obstructed: %t\n", e.IsObstructed)
+	fmt.Printf("Stopped: %t\n", e.IsStopped)
+	fmt.Printf("Service mode: %s\n", e.ServiceMode.String())
 	fmt.Println("Request Matrix:")
 	for floor := len(e.Requests) - 1; floor >= 0; floor-- {
 		fmt.Printf("Floor %d: ", floor)
@@ -0,0 +1,26 @@
+package elevator
+
+import "testing"
+
+func TestElevatorHealth(t *testing.T) {
+	tests := []struct {
+		health      ElevatorHealth
+		wantHealthy bool
+		wantString  string
+	}{
+		{ElevatorHealth{}, true, "healthy"},
+		{ElevatorHealth{Obstructed: true}, false, "obstructed"},
+		{ElevatorHealth{DoorStuck: true}, false, "door stuck"},
+		{ElevatorHealth{MotorFault: true}, false, "motor fault"},
+		{ElevatorHealth{Maintenance: true}, false, "maintenance"},
+		{ElevatorHealth{Obstructed: true, MotorFault: true}, false, "obstructed, motor fault"},
+	}
+	for _, test := range tests {
+		if got := test.health.Healthy(); got != test.wantHealthy {
+			t.Errorf("%+v: Healthy() = %v, want %v", test.health, got, test.wantHealthy)
+		}
+		if got := test.health.String(); got != test.wantString {
+			t.Errorf("%+v: got %q, want %q", test.health, got, test.wantString)
+		}
+	}
+}
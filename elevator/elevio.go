@@ -4,6 +4,7 @@ import (
 	"elev/config"
 	"elev/util/timer"
 	"fmt"
+	"io"
 	"net"
 	"sync"
 	"time"
@@ -11,10 +12,6 @@ import (
 
 const pollInterval = 20 * time.Millisecond
 
-var driverIsInitialized bool = false
-var driverMutex sync.Mutex
-var serverConnection net.Conn
-
 type MotorDirection int
 
 const (
@@ -36,57 +33,233 @@ type ButtonEvent struct {
 	Button ButtonType
 }
 
-func Init(addr string, numFloors int) {
-	if driverIsInitialized {
-		fmt.Println("Driver already initialized!")
-		return
-	}
-	driverMutex = sync.Mutex{}
-	var err error
-	serverConnection, err = net.Dial("tcp", addr)
+// Driver is the hardware interface of a single elevator car
+type Driver interface {
+	SetMotorDirection(dir MotorDirection)
+	SetButtonLamp(button ButtonType, floor int, value bool)
+	SetFloorIndicator(floor int)
+	SetDoorOpenLamp(value bool)
+	SetStopLamp(value bool)
+	ButtonIsPressed(button ButtonType, floor int) bool
+	GetFloor() int
+	StopIsPressed() bool
+	Obstructed() bool
+	IsConnected() bool
+}
+
+// TCPDriver talks to an elevator server (hardware or simulator) over the 4-byte elevio protocol.
+// If the connection is lost, it reconnects in the background with exponential backoff.
+// While disconnected, outputs are only remembered and inputs read as released/between floors.
+// The remembered outputs are written to the server again once the connection is back.
+type TCPDriver struct {
+	mu           sync.Mutex
+	addr         string
+	conn         net.Conn // nil while disconnected
+	reconnecting bool
+
+	// the last outputs written, used to resync the server after a reconnect
+	motorDirection MotorDirection
+	buttonLamps    [config.NUM_FLOORS][config.NUM_BUTTONS]bool
+	floorIndicator int
+	doorOpenLamp   bool
+	stopLamp       bool
+
+	lastObstructed bool
+}
+
+// NewTCPDriver connects to the elevator server at addr. If the server is not up yet, the driver starts out
+// disconnected and keeps trying in the background, as after a lost connection.
+func NewTCPDriver(addr string) *TCPDriver {
+	drv := &TCPDriver{addr: addr}
+	conn, err := net.DialTimeout("tcp", addr, config.DRIVER_IO_TIMEOUT)
 	if err != nil {
-		panic(err.Error())
+		fmt.Printf("Could not connect to Elevator Server at %s: %v, retrying\n", addr, err)
+		drv.reconnecting = true
+		go drv.reconnect()
+		return drv
 	}
-	driverIsInitialized = true
+	drv.conn = conn
+	return drv
 }
 
-func SetMotorDirection(dir MotorDirection) {
-	write([4]byte{1, byte(dir), 0, 0})
+func (drv *TCPDriver) SetMotorDirection(dir MotorDirection) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.motorDirection = dir
+	drv.write([4]byte{1, byte(dir), 0, 0})
 }
 
-func SetButtonLamp(button ButtonType, floor int, value bool) {
-	write([4]byte{2, byte(button), byte(floor), toByte(value)})
+func (drv *TCPDriver) SetButtonLamp(button ButtonType, floor int, value bool) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.buttonLamps[floor][button] = value
+	drv.write([4]byte{2, byte(button), byte(floor), toByte(value)})
 }
 
-func SetAllLights(elev *Elevator) {
-	for floor := 0; floor < config.NUM_FLOORS; floor++ {
-		SetButtonLamp(ButtonCab, floor, elev.Requests[floor][ButtonCab])
+func (drv *TCPDriver) SetFloorIndicator(floor int) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.floorIndicator = floor
+	drv.write([4]byte{3, byte(floor), 0, 0})
+}
 
-		for i := 0; i < config.NUM_BUTTONS-1; i++ {
-			SetButtonLamp(ButtonType(i), floor, elev.HallLightStates[floor][ButtonType(i)])
-		}
+func (drv *TCPDriver) SetDoorOpenLamp(value bool) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.doorOpenLamp = value
+	drv.write([4]byte{4, toByte(value), 0, 0})
+}
+
+func (drv *TCPDriver) SetStopLamp(value bool) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.stopLamp = value
+	drv.write([4]byte{5, toByte(value), 0, 0})
+}
+
+func (drv *TCPDriver) ButtonIsPressed(button ButtonType, floor int) bool {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	a, _ := drv.read([4]byte{6, byte(button), byte(floor), 0})
+	return toBool(a[1])
+}
+
+func (drv *TCPDriver) GetFloor() int {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	a, _ := drv.read([4]byte{7, 0, 0, 0})
+	if a[1] != 0 {
+		return int(a[2])
+	} else {
+		return -1
+	}
+}
+
+func (drv *TCPDriver) StopIsPressed() bool {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	a, _ := drv.read([4]byte{8, 0, 0, 0})
+	return toBool(a[1])
+}
+
+// Obstructed keeps reporting the last known state of the switch while disconnected,
+// so that a lost connection does not look like the obstruction being removed
+func (drv *TCPDriver) Obstructed() bool {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	a, ok := drv.read([4]byte{9, 0, 0, 0})
+	if ok {
+		drv.lastObstructed = toBool(a[1])
 	}
+	return drv.lastObstructed
 }
 
-func SetFloorIndicator(floor int) {
-	write([4]byte{3, byte(floor), 0, 0})
+func (drv *TCPDriver) IsConnected() bool {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	return drv.conn != nil
+}
+
+// read sends a request and waits for the reply. Must be called with mu held.
+// Returns false if the server is not connected.
+func (drv *TCPDriver) read(in [4]byte) ([4]byte, bool) {
+	var out [4]byte
+	if drv.conn == nil {
+		return out, false
+	}
+
+	drv.conn.SetDeadline(time.Now().Add(config.DRIVER_IO_TIMEOUT))
+	if _, err := drv.conn.Write(in[:]); err != nil {
+		drv.connectionLost(err)
+		return out, false
+	}
+	if _, err := io.ReadFull(drv.conn, out[:]); err != nil {
+		drv.connectionLost(err)
+		return [4]byte{}, false
+	}
+	return out, true
+}
+
+// write sends a command without a reply. Must be called with mu held.
+func (drv *TCPDriver) write(in [4]byte) {
+	if drv.conn == nil {
+		return
+	}
+
+	drv.conn.SetDeadline(time.Now().Add(config.DRIVER_IO_TIMEOUT))
+	if _, err := drv.conn.Write(in[:]); err != nil {
+		drv.connectionLost(err)
+	}
+}
+
+// connectionLost closes the connection and starts reconnecting. Must be called with mu held.
+func (drv *TCPDriver) connectionLost(err error) {
+	fmt.Printf("Lost connection to Elevator Server: %v\n", err)
+	drv.conn.Close()
+	drv.conn = nil
+	if !drv.reconnecting {
+		drv.reconnecting = true
+		go drv.reconnect()
+	}
+}
+
+func (drv *TCPDriver) reconnect() {
+	backoff := config.DRIVER_RECONNECT_MIN_BACKOFF
+	for {
+		time.Sleep(backoff)
+		conn, err := net.DialTimeout("tcp", drv.addr, config.DRIVER_IO_TIMEOUT)
+		if err == nil {
+			drv.mu.Lock()
+			drv.conn = conn
+			drv.resync()
+			if drv.conn != nil {
+				fmt.Println("Reconnected to Elevator Server")
+				drv.reconnecting = false
+				drv.mu.Unlock()
+				return
+			}
+			// the connection was lost again during the resync, connectionLost did not start a new
+			// reconnect since we are already reconnecting
+			drv.mu.Unlock()
+		}
+
+		backoff *= 2
+		if backoff > config.DRIVER_RECONNECT_MAX_BACKOFF {
+			backoff = config.DRIVER_RECONNECT_MAX_BACKOFF
+		}
+	}
 }
 
-func SetDoorOpenLamp(value bool) {
-	write([4]byte{4, toByte(value), 0, 0})
+// resync writes the remembered outputs to the server. Must be called with mu held.
+func (drv *TCPDriver) resync() {
+	drv.write([4]byte{1, byte(drv.motorDirection), 0, 0})
+	for floor := 0; floor < config.NUM_FLOORS; floor++ {
+		for btn := 0; btn < config.NUM_BUTTONS; btn++ {
+			drv.write([4]byte{2, byte(btn), byte(floor), toByte(drv.buttonLamps[floor][btn])})
+		}
+	}
+	drv.write([4]byte{3, byte(drv.floorIndicator), 0, 0})
+	drv.write([4]byte{4, toByte(drv.doorOpenLamp), 0, 0})
+	drv.write([4]byte{5, toByte(drv.stopLamp), 0, 0})
 }
 
-func SetStopLamp(value bool) {
-	write([4]byte{5, toByte(value), 0, 0})
+func SetAllLights(drv Driver, elev *Elevator) {
+	for floor := 0; floor < config.NUM_FLOORS; floor++ {
+		drv.SetButtonLamp(ButtonCab, floor, elev.Requests[floor][ButtonCab])
+
+		for i := 0; i < config.NUM_BUTTONS-1; i++ {
+			drv.SetButtonLamp(ButtonType(i), floor, elev.HallLightStates[floor][ButtonType(i)])
+		}
+	}
 }
 
-func PollButtons(receiver chan<- ButtonEvent) {
+func PollButtons(drv Driver, receiver chan<- ButtonEvent) {
 	prev := make([][3]bool, config.NUM_FLOORS)
 	for {
 		time.Sleep(pollInterval)
 		for floor := 0; floor < config.NUM_FLOORS; floor++ {
 			for button := ButtonType(0); button < 3; button++ {
-				v := ButtonIsPressed(button, floor)
+				v := drv.ButtonIsPressed(button, floor)
 				if v != prev[floor][button] && v {
 					receiver <- ButtonEvent{floor, ButtonType(button)}
 				}
@@ -96,11 +269,11 @@ func PollButtons(receiver chan<- ButtonEvent) {
 	}
 }
 
-func PollFloorSensor(receiver chan<- int) {
+func PollFloorSensor(drv Driver, receiver chan<- int) {
 	prev := -1
 	for {
 		time.Sleep(pollInterval)
-		v := GetFloor()
+		v := drv.GetFloor()
 		if v != prev && v != -1 {
 			receiver <- v
 		}
@@ -108,11 +281,11 @@ func PollFloorSensor(receiver chan<- int) {
 	}
 }
 
-func PollStopButton(receiver chan<- bool) {
+func PollStopButton(drv Driver, receiver chan<- bool) {
 	prev := false
 	for {
 		time.Sleep(pollInterval)
-		v := StopIsPressed()
+		v := drv.StopIsPressed()
 		if v != prev {
 			receiver <- v
 		}
@@ -120,11 +293,24 @@ func PollStopButton(receiver chan<- bool) {
 	}
 }
 
-func PollObstructionSwitch(receiver chan<- bool) {
+func PollObstructionSwitch(drv Driver, receiver chan<- bool) {
 	prev := false
 	for {
 		time.Sleep(pollInterval)
-		v := Obstructed()
+		v := drv.Obstructed()
+		if v != prev {
+			receiver <- v
+		}
+		prev = v
+	}
+}
+
+// PollServerConnection reports the changes of the connection to the server from the state wasConnected
+func PollServerConnection(drv Driver, wasConnected bool, receiver chan<- bool) {
+	prev := wasConnected
+	for {
+		time.Sleep(pollInterval)
+		v := drv.IsConnected()
 		if v != prev {
 			receiver <- v
 		}
@@ -169,58 +355,6 @@ func PollDoorStuck(inTimer timer.Timer, receiver chan<- bool) {
 //     }
 // }
 
-func ButtonIsPressed(button ButtonType, floor int) bool {
-	a := read([4]byte{6, byte(button), byte(floor), 0})
-	return toBool(a[1])
-}
-
-func GetFloor() int {
-	a := read([4]byte{7, 0, 0, 0})
-	if a[1] != 0 {
-		return int(a[2])
-	} else {
-		return -1
-	}
-}
-
-func StopIsPressed() bool {
-	a := read([4]byte{8, 0, 0, 0})
-	return toBool(a[1])
-}
-
-func Obstructed() bool {
-	a := read([4]byte{9, 0, 0, 0})
-	return toBool(a[1])
-}
-
-func read(in [4]byte) [4]byte {
-	driverMutex.Lock()
-	defer driverMutex.Unlock()
-
-	_, err := serverConnection.Write(in[:])
-	if err != nil {
-		panic("Lost connection to Elevator Server")
-	}
-
-	var out [4]byte
-	_, err = serverConnection.Read(out[:])
-	if err != nil {
-		panic("Lost connection to Elevator Server")
-	}
-
-	return out
-}
-
-func write(in [4]byte) {
-	driverMutex.Lock()
-	defer driverMutex.Unlock()
-
-	_, err := serverConnection.Write(in[:])
-	if err != nil {
-		panic("Lost connection to Elevator Server")
-	}
-}
-
 func toByte(a bool) byte {
 	var b byte = 0
 	if a {
@@ -0,0 +1,152 @@
+package elevator
+
+import (
+	"elev/config"
+	"sync"
+)
+
+// MemoryDriver is an in-memory Driver backend. Outputs (motor, lamps) are recorded
+// and inputs (buttons, floor sensor, stop, obstruction) are set directly, which makes it
+// usable as a fake in tests or as the hardware of a purely simulated car.
+type MemoryDriver struct {
+	mu sync.Mutex
+
+	motorDirection MotorDirection
+	buttonLamps    [config.NUM_FLOORS][config.NUM_BUTTONS]bool
+	floorIndicator int
+	doorOpenLamp   bool
+	stopLamp       bool
+
+	buttonsPressed [config.NUM_FLOORS][config.NUM_BUTTONS]bool
+	floor          int
+	stopPressed    bool
+	obstructed     bool
+	connected      bool
+}
+
+func NewMemoryDriver() *MemoryDriver {
+	return &MemoryDriver{floor: -1, connected: true}
+}
+
+func (drv *MemoryDriver) SetMotorDirection(dir MotorDirection) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.motorDirection = dir
+}
+
+func (drv *MemoryDriver) SetButtonLamp(button ButtonType, floor int, value bool) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.buttonLamps[floor][button] = value
+}
+
+func (drv *MemoryDriver) SetFloorIndicator(floor int) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.floorIndicator = floor
+}
+
+func (drv *MemoryDriver) SetDoorOpenLamp(value bool) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.doorOpenLamp = value
+}
+
+func (drv *MemoryDriver) SetStopLamp(value bool) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.stopLamp = value
+}
+
+func (drv *MemoryDriver) ButtonIsPressed(button ButtonType, floor int) bool {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	return drv.buttonsPressed[floor][button]
+}
+
+func (drv *MemoryDriver) GetFloor() int {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	return drv.floor
+}
+
+func (drv *MemoryDriver) StopIsPressed() bool {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	return drv.stopPressed
+}
+
+func (drv *MemoryDriver) Obstructed() bool {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	return drv.obstructed
+}
+
+func (drv *MemoryDriver) IsConnected() bool {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	return drv.connected
+}
+
+// SetButtonPressed sets the state of a button as seen by the poller
+func (drv *MemoryDriver) SetButtonPressed(button ButtonType, floor int, pressed bool) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.buttonsPressed[floor][button] = pressed
+}
+
+// SetFloor sets the floor sensor, -1 means between floors
+func (drv *MemoryDriver) SetFloor(floor int) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.floor = floor
+}
+
+func (drv *MemoryDriver) SetStopPressed(pressed bool) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.stopPressed = pressed
+}
+
+func (drv *MemoryDriver) SetObstructed(isObstructed bool) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.obstructed = isObstructed
+}
+
+// SetConnected simulates losing or regaining the connection to the hardware
+func (drv *MemoryDriver) SetConnected(connected bool) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.connected = connected
+}
+
+func (drv *MemoryDriver) MotorDirection() MotorDirection {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	return drv.motorDirection
+}
+
+func (drv *MemoryDriver) ButtonLamp(button ButtonType, floor int) bool {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	return drv.buttonLamps[floor][button]
+}
+
+func (drv *MemoryDriver) FloorIndicator() int {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	return drv.floorIndicator
+}
+
+func (drv *MemoryDriver) DoorOpenLamp() bool {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	return drv.doorOpenLamp
+}
+
+func (drv *MemoryDriver) StopLamp() bool {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	return drv.stopLamp
+}
@@ -49,6 +49,10 @@ func RequestsHere(e Elevator) bool {
 	return false
 }
 
+func RequestsAny(e Elevator) bool {
+	return RequestsAbove(e) || RequestsBelow(e) || RequestsHere(e)
+}
+
 func RequestsChooseDirection(e Elevator) DirBehaviorPair {
 	switch e.Dir {
 	case DirectionUp:
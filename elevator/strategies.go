@@ -0,0 +1,155 @@
+package elevator
+
+import (
+	"elev/config"
+	"fmt"
+)
+
+// RequestStrategy is a scheduling policy for how a single car services its requests
+type RequestStrategy interface {
+	// ChooseDirection picks the next direction and behavior of an elevator that is not moving
+	ChooseDirection(e Elevator) DirBehaviorPair
+	// ShouldStop decides whether a moving elevator should stop at its current floor
+	ShouldStop(e Elevator) bool
+	// ShouldClearImmediately decides whether a new request can be served right away by an elevator with its door open
+	ShouldClearImmediately(e Elevator, btnFloor int, btnType ButtonType) bool
+	// ClearAtCurrentFloor removes the requests served by stopping at the current floor, and returns the cleared requests
+	ClearAtCurrentFloor(e Elevator) (Elevator, []ButtonEvent)
+}
+
+// Strategy names used in configuration
+const (
+	CollectiveStrategyName = "collective"
+	ClearAllStrategyName   = "clearall"
+	ScanStrategyName       = "scan"
+)
+
+// StrategyFromName returns the strategy with the given configuration name
+func StrategyFromName(name string) (RequestStrategy, error) {
+	switch name {
+	case CollectiveStrategyName:
+		return CollectiveStrategy{}, nil
+	case ClearAllStrategyName:
+		return ClearAllStrategy{}, nil
+	case ScanStrategyName:
+		return ScanStrategy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown request strategy %q", name)
+	}
+}
+
+// CollectiveStrategy is the default collective control policy: the car keeps its direction while
+// there are requests ahead, and only clears hall requests in its direction of travel.
+type CollectiveStrategy struct{}
+
+func (CollectiveStrategy) ChooseDirection(e Elevator) DirBehaviorPair {
+	return RequestsChooseDirection(e)
+}
+
+func (CollectiveStrategy) ShouldStop(e Elevator) bool {
+	return RequestsShouldStop(e)
+}
+
+func (CollectiveStrategy) ShouldClearImmediately(e Elevator, btnFloor int, btnType ButtonType) bool {
+	return RequestsShouldClearImmediately(e, btnFloor, btnType)
+}
+
+func (CollectiveStrategy) ClearAtCurrentFloor(e Elevator) (Elevator, []ButtonEvent) {
+	return RequestsClearAtCurrentFloor(e)
+}
+
+// ClearAllStrategy assumes everyone waiting at a floor enters when the door opens:
+// the car stops for any request at a floor, and stopping clears all requests there.
+type ClearAllStrategy struct{}
+
+func (ClearAllStrategy) ChooseDirection(e Elevator) DirBehaviorPair {
+	return RequestsChooseDirection(e)
+}
+
+func (ClearAllStrategy) ShouldStop(e Elevator) bool {
+	switch e.Dir {
+	case DirectionDown:
+		return RequestsHere(e) || !RequestsBelow(e)
+	case DirectionUp:
+		return RequestsHere(e) || !RequestsAbove(e)
+	default:
+		return true
+	}
+}
+
+func (ClearAllStrategy) ShouldClearImmediately(e Elevator, btnFloor int, btnType ButtonType) bool {
+	return e.Floor == btnFloor
+}
+
+func (ClearAllStrategy) ClearAtCurrentFloor(e Elevator) (Elevator, []ButtonEvent) {
+	if e.Floor < 0 || e.Floor >= config.NUM_FLOORS {
+		return e, nil
+	}
+
+	clearedRequests := make([]ButtonEvent, 0)
+	for btn := ButtonType(0); btn < config.NUM_BUTTONS; btn++ {
+		if e.Requests[e.Floor][btn] {
+			e.Requests[e.Floor][btn] = false
+			if btn != ButtonCab {
+				clearedRequests = append(clearedRequests, ButtonEvent{Floor: e.Floor, Button: btn})
+			}
+		}
+	}
+	return e, clearedRequests
+}
+
+// ScanStrategy sweeps the whole shaft: while it has requests, the car travels to the end floor
+// in its direction before reversing, stopping on the way for requests in its direction.
+type ScanStrategy struct{}
+
+func (ScanStrategy) ChooseDirection(e Elevator) DirBehaviorPair {
+	if !RequestsAbove(e) && !RequestsBelow(e) && !RequestsHere(e) {
+		return DirBehaviorPair{DirectionStop, Idle}
+	}
+
+	switch e.Dir {
+	case DirectionUp:
+		if e.Floor < config.NUM_FLOORS-1 {
+			if e.Requests[e.Floor][ButtonHallUp] || e.Requests[e.Floor][ButtonCab] {
+				return DirBehaviorPair{DirectionUp, DoorOpen}
+			}
+			return DirBehaviorPair{DirectionUp, Moving}
+		}
+		if RequestsHere(e) {
+			return DirBehaviorPair{DirectionDown, DoorOpen}
+		}
+		return DirBehaviorPair{DirectionDown, Moving}
+	case DirectionDown:
+		if e.Floor > 0 {
+			if e.Requests[e.Floor][ButtonHallDown] || e.Requests[e.Floor][ButtonCab] {
+				return DirBehaviorPair{DirectionDown, DoorOpen}
+			}
+			return DirBehaviorPair{DirectionDown, Moving}
+		}
+		if RequestsHere(e) {
+			return DirBehaviorPair{DirectionUp, DoorOpen}
+		}
+		return DirBehaviorPair{DirectionUp, Moving}
+	default:
+		return RequestsChooseDirection(e)
+	}
+}
+
+func (ScanStrategy) ShouldStop(e Elevator) bool {
+	switch e.Dir {
+	case DirectionDown:
+		return e.Requests[e.Floor][ButtonHallDown] || e.Requests[e.Floor][ButtonCab] || e.Floor == 0
+	case DirectionUp:
+		return e.Requests[e.Floor][ButtonHallUp] || e.Requests[e.Floor][ButtonCab] || e.Floor == config.NUM_FLOORS-1
+	default:
+		return true
+	}
+}
+
+func (ScanStrategy) ShouldClearImmediately(e Elevator, btnFloor int, btnType ButtonType) bool {
+	return RequestsShouldClearImmediately(e, btnFloor, btnType)
+}
+
+func (ScanStrategy) ClearAtCurrentFloor(e Elevator) (Elevator, []ButtonEvent) {
+	return RequestsClearAtCurrentFloor(e)
+}
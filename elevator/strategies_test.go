@@ -0,0 +1,195 @@
+package elevator
+
+import (
+	"reflect"
+	"testing"
+)
+
+var strategies = map[string]RequestStrategy{
+	CollectiveStrategyName: CollectiveStrategy{},
+	ClearAllStrategyName:   ClearAllStrategy{},
+	ScanStrategyName:       ScanStrategy{},
+}
+
+// carWith returns a car at floor heading in dir, with the requests given as {floor, button} pairs
+func carWith(floor int, dir MotorDirection, requests ...ButtonEvent) Elevator {
+	e := NewElevator()
+	e.Floor = floor
+	e.Dir = dir
+	for _, request := range requests {
+		e.Requests[request.Floor][request.Button] = true
+	}
+	return e
+}
+
+func TestStrategyFromName(t *testing.T) {
+	for name, want := range strategies {
+		if got, err := StrategyFromName(name); err != nil || got != want {
+			t.Errorf("%s: got %T, %v", name, got, err)
+		}
+	}
+	if _, err := StrategyFromName("look"); err == nil {
+		t.Error("an unknown strategy name was accepted")
+	}
+}
+
+// The tables give each strategy the same requests, and show where they decide differently
+
+func TestShouldStop(t *testing.T) {
+	tests := []struct {
+		name string
+		e    Elevator
+		want map[string]bool
+	}{
+		{
+			name: "passing a hall call in the other direction, with requests ahead",
+			e:    carWith(1, DirectionUp, ButtonEvent{1, ButtonHallDown}, ButtonEvent{3, ButtonCab}),
+			want: map[string]bool{CollectiveStrategyName: false, ClearAllStrategyName: true, ScanStrategyName: false},
+		},
+		{
+			name: "a hall call in the other direction, with nothing ahead",
+			e:    carWith(2, DirectionUp, ButtonEvent{2, ButtonHallDown}),
+			want: map[string]bool{CollectiveStrategyName: true, ClearAllStrategyName: true, ScanStrategyName: false},
+		},
+		{
+			name: "nothing here, with nothing ahead",
+			e:    carWith(2, DirectionDown, ButtonEvent{3, ButtonCab}),
+			want: map[string]bool{CollectiveStrategyName: true, ClearAllStrategyName: true, ScanStrategyName: false},
+		},
+		{
+			name: "a cab call here",
+			e:    carWith(1, DirectionUp, ButtonEvent{1, ButtonCab}, ButtonEvent{3, ButtonCab}),
+			want: map[string]bool{CollectiveStrategyName: true, ClearAllStrategyName: true, ScanStrategyName: true},
+		},
+	}
+	for _, test := range tests {
+		for name, strategy := range strategies {
+			if got := strategy.ShouldStop(test.e); got != test.want[name] {
+				t.Errorf("%s, %s: got %v, want %v", test.name, name, got, test.want[name])
+			}
+		}
+	}
+}
+
+func TestChooseDirection(t *testing.T) {
+	tests := []struct {
+		name string
+		e    Elevator
+		want map[string]DirBehaviorPair
+	}{
+		{
+			name: "heading up with the only request below",
+			e:    carWith(2, DirectionUp, ButtonEvent{0, ButtonCab}),
+			want: map[string]DirBehaviorPair{
+				CollectiveStrategyName: {DirectionDown, Moving},
+				ClearAllStrategyName:   {DirectionDown, Moving},
+				ScanStrategyName:       {DirectionUp, Moving},
+			},
+		},
+		{
+			name: "heading up with a hall call down here and nothing above",
+			e:    carWith(1, DirectionUp, ButtonEvent{1, ButtonHallDown}),
+			want: map[string]DirBehaviorPair{
+				CollectiveStrategyName: {DirectionDown, DoorOpen},
+				ClearAllStrategyName:   {DirectionDown, DoorOpen},
+				ScanStrategyName:       {DirectionUp, Moving},
+			},
+		},
+		{
+			name: "standing still with requests above and below",
+			e:    carWith(1, DirectionStop, ButtonEvent{0, ButtonCab}, ButtonEvent{3, ButtonHallDown}),
+			want: map[string]DirBehaviorPair{
+				CollectiveStrategyName: {DirectionUp, Moving},
+				ClearAllStrategyName:   {DirectionUp, Moving},
+				ScanStrategyName:       {DirectionUp, Moving},
+			},
+		},
+		{
+			name: "no requests",
+			e:    carWith(1, DirectionDown),
+			want: map[string]DirBehaviorPair{
+				CollectiveStrategyName: {DirectionStop, Idle},
+				ClearAllStrategyName:   {DirectionStop, Idle},
+				ScanStrategyName:       {DirectionStop, Idle},
+			},
+		},
+	}
+	for _, test := range tests {
+		for name, strategy := range strategies {
+			if got := strategy.ChooseDirection(test.e); got != test.want[name] {
+				t.Errorf("%s, %s: got %v, want %v", test.name, name, got, test.want[name])
+			}
+		}
+	}
+}
+
+func TestClearAtCurrentFloor(t *testing.T) {
+	both := carWith(1, DirectionUp, ButtonEvent{1, ButtonHallUp}, ButtonEvent{1, ButtonHallDown}, ButtonEvent{1, ButtonCab}, ButtonEvent{3, ButtonCab})
+	tests := []struct {
+		name        string
+		e           Elevator
+		wantCleared map[string][]ButtonEvent
+	}{
+		{
+			name: "hall calls both ways here, heading up with requests above",
+			e:    both,
+			wantCleared: map[string][]ButtonEvent{
+				CollectiveStrategyName: {{1, ButtonHallUp}},
+				ClearAllStrategyName:   {{1, ButtonHallUp}, {1, ButtonHallDown}},
+				ScanStrategyName:       {{1, ButtonHallUp}},
+			},
+		},
+		{
+			name: "a hall call down here, heading up with nothing above",
+			e:    carWith(2, DirectionUp, ButtonEvent{2, ButtonHallDown}),
+			wantCleared: map[string][]ButtonEvent{
+				CollectiveStrategyName: {{2, ButtonHallDown}},
+				ClearAllStrategyName:   {{2, ButtonHallDown}},
+				ScanStrategyName:       {{2, ButtonHallDown}},
+			},
+		},
+	}
+	for _, test := range tests {
+		for name, strategy := range strategies {
+			e, cleared := strategy.ClearAtCurrentFloor(test.e)
+			if !reflect.DeepEqual(cleared, test.wantCleared[name]) {
+				t.Errorf("%s, %s: cleared %v, want %v", test.name, name, cleared, test.wantCleared[name])
+			}
+			if e.Requests[e.Floor][ButtonCab] {
+				t.Errorf("%s, %s: the cab call here was not cleared", test.name, name)
+			}
+			for _, request := range cleared {
+				if e.Requests[request.Floor][request.Button] {
+					t.Errorf("%s, %s: %v reported cleared but still set", test.name, name, request)
+				}
+			}
+		}
+	}
+}
+
+func TestShouldClearImmediately(t *testing.T) {
+	// the door is open at floor 1 with the car heading up
+	e := carWith(1, DirectionUp)
+	tests := []struct {
+		name  string
+		floor int
+		btn   ButtonType
+		want  map[string]bool
+	}{
+		{"a hall call up here", 1, ButtonHallUp,
+			map[string]bool{CollectiveStrategyName: true, ClearAllStrategyName: true, ScanStrategyName: true}},
+		{"a hall call down here", 1, ButtonHallDown,
+			map[string]bool{CollectiveStrategyName: false, ClearAllStrategyName: true, ScanStrategyName: false}},
+		{"a cab call here", 1, ButtonCab,
+			map[string]bool{CollectiveStrategyName: true, ClearAllStrategyName: true, ScanStrategyName: true}},
+		{"a call at another floor", 2, ButtonHallUp,
+			map[string]bool{CollectiveStrategyName: false, ClearAllStrategyName: false, ScanStrategyName: false}},
+	}
+	for _, test := range tests {
+		for name, strategy := range strategies {
+			if got := strategy.ShouldClearImmediately(e, test.floor, test.btn); got != test.want[name] {
+				t.Errorf("%s, %s: got %v, want %v", test.name, name, got, test.want[name])
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package elevator_fsm
+
+import (
+	"elev/config"
+	"elev/elevator"
+	"sync"
+	"time"
+)
+
+// Controller runs an FSM against real hardware. It holds the current elevator state and
+// executes the actions of every transition on its driver and timers.
+type Controller struct {
+	fsm  FSM
+	drv  elevator.Driver
+	mu   sync.Mutex
+	elev elevator.Elevator
+
+	DoorOpenTimer  *time.Timer // fires when the door has been open for DOOR_OPEN_DURATION
+	DoorStuckTimer *time.Timer // fires when the door has been open for DOOR_STUCK_DURATION
+}
+
+func NewController(fsm FSM, drv elevator.Driver) *Controller {
+	ctrl := &Controller{
+		fsm:            fsm,
+		drv:            drv,
+		elev:           elevator.NewElevator(),
+		DoorOpenTimer:  time.NewTimer(config.DOOR_OPEN_DURATION),
+		DoorStuckTimer: time.NewTimer(config.DOOR_STUCK_DURATION),
+	}
+	ctrl.DoorOpenTimer.Stop()
+	ctrl.DoorStuckTimer.Stop()
+	return ctrl
+}
+
+// Elevator returns a copy of the current elevator state. Safe to call from other goroutines.
+func (ctrl *Controller) Elevator() elevator.Elevator {
+	ctrl.mu.Lock()
+	defer ctrl.mu.Unlock()
+	return ctrl.elev
+}
+
+// Handle runs a transition and executes its actions. Returns the hall requests that were served.
+func (ctrl *Controller) Handle(ev Event) []elevator.ButtonEvent {
+	ctrl.mu.Lock()
+	newElev, actions := ctrl.fsm.Transition(ctrl.elev, ev)
+	ctrl.elev = newElev
+	ctrl.mu.Unlock()
+
+	var cleared []elevator.ButtonEvent
+	for _, action := range actions {
+		switch action.Type {
+		case SetMotorDirection:
+			ctrl.drv.SetMotorDirection(action.Dir)
+		case SetButtonLamp:
+			ctrl.drv.SetButtonLamp(action.Button, action.Floor, action.Value)
+		case SetFloorIndicator:
+			ctrl.drv.SetFloorIndicator(action.Floor)
+		case SetDoorOpenLamp:
+			ctrl.drv.SetDoorOpenLamp(action.Value)
+		case SetStopLamp:
+			ctrl.drv.SetStopLamp(action.Value)
+		case StartDoorTimer:
+			ctrl.DoorOpenTimer.Reset(config.DOOR_OPEN_DURATION)
+		case StopDoorTimer:
+			ctrl.DoorOpenTimer.Stop()
+		case StartDoorStuckTimer:
+			ctrl.DoorStuckTimer.Reset(config.DOOR_STUCK_DURATION)
+		case StopDoorStuckTimer:
+			ctrl.DoorStuckTimer.Stop()
+		case HallRequestCleared:
+			cleared = append(cleared, elevator.ButtonEvent{Floor: action.Floor, Button: action.Button})
+		}
+	}
+	return cleared
+}
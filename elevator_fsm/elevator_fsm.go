@@ -1,252 +1,479 @@
+// Package elevator_fsm implements the state machine of a single elevator car.
+// Transitions are pure: FSM.Transition takes the current elevator and an event and returns the
+// new elevator together with the actions (motor, lamps, timers) that must be carried out.
+// Controller is the thin shell that executes those actions on a driver and a set of timers.
 package elevator_fsm
 
 import (
 	"elev/config"
 	"elev/elevator"
-	"fmt"
-	"time"
 )
 
-var elev elevator.Elevator
+type EventType int
+
+const (
+	InitEvent            EventType = iota // The elevator has started, the car position is unknown
+	RequestEvent                          // A new request (cab button or hall assignment) at Floor/Button
+	RequestRemovedEvent                   // A hall assignment at Floor/Button is withdrawn
+	FloorArrivalEvent                     // The floor sensor has detected Floor
+	DoorTimeoutEvent                      // The door has been open for DOOR_OPEN_DURATION
+	ObstructionEvent                      // The obstruction switch has changed to IsObstructed
+	HallLightsEvent                       // The node has sent new hall light states
+	StopButtonEvent                       // The stop button is pressed, Floor is the floor sensor reading
+	ServerReconnectEvent                  // The connection to the elevator server is back, Floor is the floor sensor reading
+	ServiceModeEvent                      // The car is put in ServiceMode, Floor is the park floor (-1 for none)
+	ParkEvent                             // The car has been idle for a while and should park at Floor
+)
 
-func GetElevator() elevator.Elevator {
-	return elev
+type Event struct {
+	Type            EventType
+	Floor           int // -1 means between floors for events carrying a floor sensor reading
+	Button          elevator.ButtonType
+	IsObstructed    bool
+	HallLightStates [config.NUM_FLOORS][config.NUM_BUTTONS - 1]bool
+	ServiceMode     elevator.ServiceMode
 }
 
-func InitFSM() {
-	elev = elevator.NewElevator()
+type ActionType int
+
+const (
+	SetMotorDirection   ActionType = iota // Dir
+	SetButtonLamp                         // Button, Floor, Value
+	SetFloorIndicator                     // Floor
+	SetDoorOpenLamp                       // Value
+	SetStopLamp                           // Value
+	StartDoorTimer                        // (re)start the door open timer
+	StopDoorTimer                         // stop the door open timer
+	StartDoorStuckTimer                   // (re)start the door stuck timer
+	StopDoorStuckTimer                    // stop the door stuck timer
+	HallRequestCleared                    // the hall request at Floor/Button has been served
+)
 
-	for floor := 0; floor < config.NUM_FLOORS; floor++ {
-		for btn := 0; btn < config.NUM_BUTTONS; btn++ {
-			elevator.SetButtonLamp(elevator.ButtonType(btn), floor, false)
-		}
-	}
-	OnInitBetweenFloors()
+type Action struct {
+	Type   ActionType
+	Dir    elevator.MotorDirection
+	Floor  int
+	Button elevator.ButtonType
+	Value  bool
 }
 
-func OnInitBetweenFloors() {
-	elevator.SetMotorDirection(elevator.DirectionDown)
-	elev.Dir = elevator.DirectionDown
-	elev.Behavior = elevator.Moving
+// FSM holds the transition function of the elevator. It has no elevator state of its own,
+// so any number of elevators can share it.
+type FSM struct {
+	Strategy elevator.RequestStrategy // how requests are serviced, collective control if nil
 }
 
-func OnRequestButtonPress(btnFloor int, btnType elevator.ButtonType, doorOpenTimer *time.Timer) []elevator.ButtonEvent {
-	fmt.Printf("new local elevator assignment: %d, %s)\n", btnFloor, btnType.String())
+func (fsm FSM) strategy() elevator.RequestStrategy {
+	if fsm.Strategy == nil {
+		return elevator.CollectiveStrategy{}
+	}
+	return fsm.Strategy
+}
 
-	// Compute new elevator state
-	newState, clearedEvents, resetDoorTimer := HandleButtonEvent(btnFloor, btnType, doorOpenTimer)
+// Transition computes the next elevator state and the actions needed to get there
+func (fsm FSM) Transition(e elevator.Elevator, ev Event) (elevator.Elevator, []Action) {
+	old := e
+	var actions []Action
 
-	// Apply side effects
-	if resetDoorTimer {
-		doorOpenTimer.Reset(config.DOOR_OPEN_DURATION)
-		elevator.SetDoorOpenLamp(true)
-	}
+	switch ev.Type {
+	case InitEvent:
+		e, actions = fsm.initBetweenFloors(elevator.NewElevator())
+		// the hardware lamps are in an unknown state, turn them all off
+		return e, append(allLampActions(e), actions...)
+
+	case RequestEvent:
+		e, actions = fsm.onRequest(e, ev.Floor, ev.Button)
+
+	case RequestRemovedEvent:
+		e.Requests[ev.Floor][ev.Button] = false
+
+	case FloorArrivalEvent:
+		e, actions = fsm.onFloorArrival(e, ev.Floor)
+
+	case DoorTimeoutEvent:
+		e, actions = fsm.onDoorTimeout(e)
+
+	case ObstructionEvent:
+		e.IsObstructed = ev.IsObstructed
 
-	if newState.Behavior == elevator.Moving && elev.Behavior != elevator.Moving {
-		elevator.SetMotorDirection(newState.Dir)
+	case HallLightsEvent:
+		e.HallLightStates = ev.HallLightStates
+
+	case StopButtonEvent:
+		e, actions = fsm.onStopButton(e, ev.Floor)
+
+	case ServerReconnectEvent:
+		e, actions = fsm.onServerReconnect(e, ev.Floor)
+		// the server may have been restarted, so rewrite every lamp
+		return e, append(allLampActions(e), actions...)
+
+	case ServiceModeEvent:
+		e, actions = fsm.onServiceMode(e, ev.ServiceMode, ev.Floor)
+
+	case ParkEvent:
+		e, actions = fsm.onPark(e, ev.Floor)
 	}
 
-	elev = newState
-	elevator.SetAllLights(&elev)
+	return e, append(actions, lampActions(old, e)...)
+}
 
-	return clearedEvents
+// initBetweenFloors moves the car downwards until the floor sensor finds a floor
+func (fsm FSM) initBetweenFloors(e elevator.Elevator) (elevator.Elevator, []Action) {
+	e.Dir = elevator.DirectionDown
+	e.Behavior = elevator.Moving
+	return e, []Action{{Type: SetMotorDirection, Dir: elevator.DirectionDown}}
 }
 
-// HandleButtonEvent is a pure function that computes state changes.
-// It is flawed though, since it has access to the global state elev, making it impure.
-// It should be refactored to take the elevator state as an argument.
-func HandleButtonEvent(btnFloor int, btnType elevator.ButtonType, doorOpenTimer *time.Timer) (elevator.Elevator, []elevator.ButtonEvent, bool) {
+func (fsm FSM) onRequest(e elevator.Elevator, btnFloor int, btnType elevator.ButtonType) (elevator.Elevator, []Action) {
+	var actions []Action
+
+	// calls are ignored until a fire recall is reset
+	if e.ServiceMode == elevator.RecallService {
+		return e, nil
+	}
+
+	// only cab calls are taken in independent service
+	if e.ServiceMode == elevator.IndependentService && btnType != elevator.ButtonCab {
+		return e, nil
+	}
 
-	newState := elev
-	resetDoorTimer := false
-	var clearedEvents []elevator.ButtonEvent
+	// a real request cancels parking
+	if e.ServiceMode == elevator.NormalService {
+		e.ParkFloor = -1
+	}
+
+	// While the emergency stop is engaged, requests are only remembered and served after the release
+	if e.IsStopped {
+		e.Requests[btnFloor][btnType] = true
+		return e, nil
+	}
 
-	// If the elevator is idle and the button is pressed in the same floor, the door should remain open
-	switch newState.Behavior {
+	switch e.Behavior {
 	case elevator.DoorOpen:
 		// If the elevator is at the requested floor, the door is open, and the button is pressed again, the door should remain open.
-		if elevator.RequestsShouldClearImmediately(newState, btnFloor, btnType) {
-			resetDoorTimer = true
+		if fsm.strategy().ShouldClearImmediately(e, btnFloor, btnType) {
+			actions = append(actions, Action{Type: StartDoorTimer})
 			if btnType != elevator.ButtonCab {
-				clearedEvents = append(clearedEvents, elevator.ButtonEvent{Floor: btnFloor, Button: btnType})
+				actions = append(actions, Action{Type: HallRequestCleared, Floor: btnFloor, Button: btnType})
 			}
 		} else {
-			newState.Requests[btnFloor][btnType] = true
+			// a door held open has no timer running, start it so the car can leave
+			if holdsDoorOpen(e) {
+				actions = append(actions, Action{Type: StartDoorTimer})
+			}
+			e.Requests[btnFloor][btnType] = true
 		}
+
 	case elevator.Moving:
-		newState.Requests[btnFloor][btnType] = true
+		e.Requests[btnFloor][btnType] = true
+
 	case elevator.Idle:
-		newState.Requests[btnFloor][btnType] = true
-		pair := elevator.RequestsChooseDirection(newState)
-		newState.Dir = pair.Dir
-		newState.Behavior = pair.Behavior
-
-		switch pair.Behavior {
-		case elevator.DoorOpen:
-			resetDoorTimer = true
-			updatedElev, events := elevator.RequestsClearAtCurrentFloor(newState)
-			newState = updatedElev
-			clearedEvents = events
-		case elevator.Moving, elevator.Idle:
-			// do nothing
-		}
+		e.Requests[btnFloor][btnType] = true
+		e, actions = fsm.startFromIdle(e)
 	}
-
-	return newState, clearedEvents, resetDoorTimer
+	return e, actions
 }
 
-func RemoveRequest(floor int, btnType elevator.ButtonType) {
-	elev.Requests[floor][btnType] = false
-	elevator.SetButtonLamp(btnType, floor, false)
+// startFromIdle picks the next direction of an idle car and starts moving or opens the door
+func (fsm FSM) startFromIdle(e elevator.Elevator) (elevator.Elevator, []Action) {
+	pair := fsm.chooseDirection(e)
+	e.Dir = pair.Dir
+	e.Behavior = pair.Behavior
+
+	switch pair.Behavior {
+	case elevator.DoorOpen:
+		actions := openDoorActions()
+		var cleared []elevator.ButtonEvent
+		e, cleared = fsm.strategy().ClearAtCurrentFloor(e)
+		return e, append(actions, clearedActions(cleared)...)
+	case elevator.Moving:
+		return e, []Action{{Type: SetMotorDirection, Dir: e.Dir}}
+	default:
+		return e, nil
+	}
 }
 
-func SetObstruction(isObstructed bool) {
-	elev.IsObstructed = isObstructed
+func (fsm FSM) onFloorArrival(e elevator.Elevator, newFloor int) (elevator.Elevator, []Action) {
+	e.Floor = newFloor
+	actions := []Action{{Type: SetFloorIndicator, Floor: newFloor}}
+
+	if e.Behavior != elevator.Moving || e.IsStopped || !fsm.shouldStop(e) {
+		return e, actions
+	}
+
+	actions = append(actions, Action{Type: SetMotorDirection, Dir: elevator.DirectionStop})
+
+	if e.ParkFloor >= 0 && !elevator.RequestsAny(e) && (e.Floor != e.ParkFloor || e.ServiceMode == elevator.NormalService) {
+		// parking moves stop without opening the door, and turn around if they were heading away from the park floor
+		e.Dir = elevator.DirectionStop
+		e.Behavior = elevator.Idle
+		if e.Floor == e.ParkFloor {
+			e.ParkFloor = -1
+			return e, actions
+		}
+		e, startActions := fsm.startFromIdle(e)
+		return e, append(actions, startActions...)
+	}
+
+	if e.ParkFloor < 0 && !elevator.RequestsHere(e) && elevator.RequestsAny(e) {
+		// nobody to serve here, as at the end of a scan sweep: turn around without opening the door
+		pair := fsm.chooseDirection(e)
+		e.Dir = pair.Dir
+		e.Behavior = pair.Behavior
+		return e, append(actions, Action{Type: SetMotorDirection, Dir: e.Dir})
+	}
+
+	actions = append(actions, openDoorActions()...)
+
+	var cleared []elevator.ButtonEvent
+	e, cleared = fsm.strategy().ClearAtCurrentFloor(e)
+	e.Behavior = elevator.DoorOpen
+	return e, append(actions, clearedActions(cleared)...)
 }
 
-func OnFloorArrival(newFloor int, doorOpenTimer *time.Timer) []elevator.ButtonEvent {
+func (fsm FSM) onDoorTimeout(e elevator.Elevator) (elevator.Elevator, []Action) {
+	// the door is kept open until the emergency stop is released
+	if e.IsStopped || e.Behavior != elevator.DoorOpen {
+		return e, nil
+	}
 
-	// rememmber and return the events cleared if the elevator stopped
-	var clearedRequests []elevator.ButtonEvent
+	if e.IsObstructed {
+		// Door is obstructed, keep it open. The door stuck timer keeps running.
+		return e, []Action{{Type: StartDoorTimer}}
+	}
 
-	elev.Floor = newFloor
-	elevator.SetFloorIndicator(elev.Floor)
+	if holdsDoorOpen(e) {
+		// Parked with the door held open, it is no longer stuck
+		return e, []Action{{Type: StopDoorStuckTimer}}
+	}
 
-	switch elev.Behavior {
-	case elevator.Moving:
-		if elevator.RequestsShouldStop(elev) {
-			var updatedElev elevator.Elevator
+	// Door can close, determine next direction and behavior
+	pair := fsm.chooseDirection(e)
+	e.Dir = pair.Dir
+	e.Behavior = pair.Behavior
 
-			elevator.SetMotorDirection(elevator.DirectionStop)
-			elevator.SetDoorOpenLamp(true)
-			doorOpenTimer.Reset(config.DOOR_OPEN_DURATION)
+	switch e.Behavior {
+	case elevator.DoorOpen:
+		// Door should stay open (new request at same floor)
+		actions := openDoorActions()
+		var cleared []elevator.ButtonEvent
+		e, cleared = fsm.strategy().ClearAtCurrentFloor(e)
+		return e, append(actions, clearedActions(cleared)...)
+	case elevator.Moving:
+		return e, append(closeDoorActions(), Action{Type: SetMotorDirection, Dir: e.Dir})
+	default:
+		return e, closeDoorActions()
+	}
+}
 
-			updatedElev, clearedRequests = elevator.RequestsClearAtCurrentFloor(elev)
-			elev = updatedElev
+// onStopButton engages the emergency stop, or releases it if it is already engaged.
+// Engaging halts the motor, lights the stop lamp and opens the door if the car is at a floor.
+// Releasing resumes normal operation: the door closes through the door timer, or the car continues from between floors.
+func (fsm FSM) onStopButton(e elevator.Elevator, floor int) (elevator.Elevator, []Action) {
+	if !e.IsStopped {
+		e.IsStopped = true
+		actions := []Action{
+			{Type: SetMotorDirection, Dir: elevator.DirectionStop},
+			{Type: SetStopLamp, Value: true},
+			{Type: StopDoorTimer},
+			{Type: StopDoorStuckTimer},
+		}
 
-			elevator.SetAllLights(&elev)
-			elev.Behavior = elevator.DoorOpen
+		if floor != -1 {
+			e.Floor = floor
+			e.Behavior = elevator.DoorOpen
+			actions = append(actions,
+				Action{Type: SetFloorIndicator, Floor: floor},
+				Action{Type: SetDoorOpenLamp, Value: true})
+		} else {
+			e.Behavior = elevator.Idle
 		}
-	default:
+		return e, actions
 	}
 
-	return clearedRequests
+	e.IsStopped = false
+	actions := []Action{{Type: SetStopLamp, Value: false}}
+
+	if floor != -1 {
+		// let the door close normally, the door timeout picks the next direction
+		e.Floor = floor
+		e.Behavior = elevator.DoorOpen
+		return e, append(actions, openDoorActions()...)
+	}
+
+	pair := fsm.chooseDirection(e)
+	if pair.Behavior == elevator.Moving {
+		e.Dir = pair.Dir
+		e.Behavior = elevator.Moving
+		return e, append(actions, Action{Type: SetMotorDirection, Dir: e.Dir})
+	}
+	// no direction to go in from between floors, find a floor first
+	e, initActions := fsm.initBetweenFloors(e)
+	return e, append(actions, initActions...)
 }
 
-func SetHallLights(lightStates [config.NUM_FLOORS][config.NUM_BUTTONS - 1]bool) {
-	elev.HallLightStates = lightStates
-	elevator.SetAllLights(&elev)
+// onServerReconnect resyncs the elevator with the hardware after the connection to the elevator server is restored.
+// If the car is between floors without moving, it is sent downwards to find a floor again.
+func (fsm FSM) onServerReconnect(e elevator.Elevator, floor int) (elevator.Elevator, []Action) {
+	if floor != -1 || e.Behavior == elevator.Moving {
+		return e, nil
+	}
+	actions := []Action{{Type: SetDoorOpenLamp, Value: false}, {Type: StopDoorTimer}, {Type: StopDoorStuckTimer}}
+	e, initActions := fsm.initBetweenFloors(e)
+	return e, append(actions, initActions...)
 }
 
-func OnDoorTimeout(doorOpenTimer *time.Timer, doorStuckTimer *time.Timer) {
-	// Calculate new state and actions (functional core)
-	newState, resetDoorOpenTimer, stopDoorStuckTimer, resetDoorStuckTimer := HandleDoorTimeout(elev)
+// onServiceMode changes the service mode of the car. Maintenance drops the hall requests, which the node gives back to the master,
+// and parks the car at parkFloor once the cab requests are served. A fire recall drops every request and goes nonstop to parkFloor.
+// Independent service drops the hall requests and holds the door open at every floor until the next cab call.
+func (fsm FSM) onServiceMode(e elevator.Elevator, mode elevator.ServiceMode, parkFloor int) (elevator.Elevator, []Action) {
+	wasHeld := holdsDoorOpen(e)
+	e.ServiceMode = mode
+	e.ParkFloor = parkFloor
+
+	switch mode {
+	case elevator.MaintenanceService, elevator.IndependentService:
+		for floor := 0; floor < config.NUM_FLOORS; floor++ {
+			e.Requests[floor][elevator.ButtonHallUp] = false
+			e.Requests[floor][elevator.ButtonHallDown] = false
+		}
+	case elevator.RecallService:
+		e.Requests = [config.NUM_FLOORS][config.NUM_BUTTONS]bool{}
+	}
+
+	if e.IsStopped {
+		return e, nil
+	}
+
+	switch e.Behavior {
+	case elevator.Idle:
+		return fsm.startFromIdle(e)
+	case elevator.DoorOpen:
+		if wasHeld && !holdsDoorOpen(e) {
+			// let the door close normally
+			return e, []Action{{Type: StartDoorTimer}}
+		}
+	}
+	return e, nil
+}
 
-	// Apply side effects (imperative shell)
-	if resetDoorOpenTimer {
-		doorOpenTimer.Reset(config.DOOR_OPEN_DURATION)
+// onPark sends an idle car in normal service to its home floor. The move is not a request,
+// so it does not open the door on arrival and any request cancels it.
+func (fsm FSM) onPark(e elevator.Elevator, floor int) (elevator.Elevator, []Action) {
+	if e.ServiceMode != elevator.NormalService || e.IsStopped || e.Behavior != elevator.Idle ||
+		e.Floor < 0 || e.Floor == floor || elevator.RequestsAny(e) {
+		return e, nil
 	}
+	e.ParkFloor = floor
+	return fsm.startFromIdle(e)
+}
 
-	if stopDoorStuckTimer {
-		doorStuckTimer.Stop()
-		elevator.SetDoorOpenLamp(false)
+// chooseDirection is the strategy's choice, extended with parking: a car without requests heads for its park floor,
+// and keeps its door open there if the service mode holds it open
+func (fsm FSM) chooseDirection(e elevator.Elevator) elevator.DirBehaviorPair {
+	pair := fsm.strategy().ChooseDirection(e)
+	if pair.Behavior != elevator.Idle || e.Floor < 0 {
+		return pair
 	}
 
-	if resetDoorStuckTimer {
-		doorStuckTimer.Reset(config.DOOR_STUCK_DURATION)
+	switch {
+	case e.ParkFloor >= 0 && e.Floor < e.ParkFloor:
+		return elevator.DirBehaviorPair{Dir: elevator.DirectionUp, Behavior: elevator.Moving}
+	case e.ParkFloor >= 0 && e.Floor > e.ParkFloor:
+		return elevator.DirBehaviorPair{Dir: elevator.DirectionDown, Behavior: elevator.Moving}
+	case holdsDoorOpen(e):
+		return elevator.DirBehaviorPair{Dir: elevator.DirectionStop, Behavior: elevator.DoorOpen}
+	default:
+		return pair
 	}
+}
 
-	// Handle motor direction changes if state changed
-	if newState.Behavior != elev.Behavior {
-		if newState.Behavior == elevator.Moving {
-			elevator.SetMotorDirection(newState.Dir)
-		} else if elev.Behavior == elevator.DoorOpen && newState.Behavior != elevator.DoorOpen {
-			elevator.SetDoorOpenLamp(false)
+// shouldStop is the strategy's choice, except that a car without requests only stops at its park floor,
+// or right away if it is heading away from it
+func (fsm FSM) shouldStop(e elevator.Elevator) bool {
+	if e.ParkFloor >= 0 && !elevator.RequestsAny(e) {
+		switch e.Dir {
+		case elevator.DirectionUp:
+			return e.Floor >= e.ParkFloor
+		case elevator.DirectionDown:
+			return e.Floor <= e.ParkFloor
+		default:
+			return true
 		}
 	}
+	return fsm.strategy().ShouldStop(e)
+}
 
-	// Update the elevator state
-	elev = newState
+// holdsDoorOpen tells whether the car stands at a floor in a mode that keeps the door open until it gets a request
+func holdsDoorOpen(e elevator.Elevator) bool {
+	switch e.ServiceMode {
+	case elevator.MaintenanceService, elevator.RecallService:
+		return e.Floor == e.ParkFloor && !elevator.RequestsAny(e)
+	case elevator.IndependentService:
+		return e.Floor >= 0 && !elevator.RequestsAny(e)
+	default:
+		return false
+	}
+}
 
-	// Update lights based on the new state
-	elevator.SetAllLights(&elev)
+// openDoorActions opens the door. The door stuck timer runs from the moment the door opens, so a door that cannot
+// close is reported DOOR_STUCK_DURATION after it opened.
+func openDoorActions() []Action {
+	return []Action{
+		{Type: SetDoorOpenLamp, Value: true},
+		{Type: StartDoorTimer},
+		{Type: StartDoorStuckTimer},
+	}
 }
 
-// HandleDoorTimeout is also flawed, since it has access to the global state elev, making it impure.
-// It should be refactored to take the elevator state as an argument.
-func HandleDoorTimeout(elev elevator.Elevator) (elevator.Elevator, bool, bool, bool) {
+func closeDoorActions() []Action {
+	return []Action{
+		{Type: SetDoorOpenLamp, Value: false},
+		{Type: StopDoorStuckTimer},
+	}
+}
 
-	newState := elev
-	resetDoorOpenTimer := false
-	stopDoorStuckTimer := false
-	resetDoorStuckTimer := false
+func clearedActions(cleared []elevator.ButtonEvent) []Action {
+	actions := make([]Action, 0, len(cleared))
+	for _, buttonEvent := range cleared {
+		if buttonEvent.Button != elevator.ButtonCab {
+			actions = append(actions, Action{Type: HallRequestCleared, Floor: buttonEvent.Floor, Button: buttonEvent.Button})
+		}
+	}
+	return actions
+}
 
-	switch newState.Behavior {
-	case elevator.DoorOpen:
-		if newState.IsObstructed {
-			// Door is obstructed, keep it open
-			resetDoorOpenTimer = true
-		} else {
-			// Door can close, determine next action
-			stopDoorStuckTimer = true
-
-			// Determine next direction and behavior
-			pair := elevator.RequestsChooseDirection(newState)
-			newState.Dir = pair.Dir
-			newState.Behavior = pair.Behavior
-
-			switch newState.Behavior {
-			case elevator.DoorOpen:
-				// Door should stay open (new request at same floor)
-				resetDoorOpenTimer = true
-				resetDoorStuckTimer = true
-
-				// Clear requests at the current floor
-				updatedElev, _ := elevator.RequestsClearAtCurrentFloor(newState)
-				newState = updatedElev
-			case elevator.Moving, elevator.Idle:
-				// Door should close, elevator moves or stays idle
+// lampValue is the value of a button lamp: cab lamps show our own requests, hall lamps show the global hall requests.
+// All lamps are off during a fire recall.
+func lampValue(e elevator.Elevator, floor int, btn elevator.ButtonType) bool {
+	if e.ServiceMode == elevator.RecallService {
+		return false
+	}
+	if btn == elevator.ButtonCab {
+		return e.Requests[floor][elevator.ButtonCab]
+	}
+	return e.HallLightStates[floor][btn]
+}
+
+// lampActions returns the lamp changes between two states
+func lampActions(old elevator.Elevator, e elevator.Elevator) []Action {
+	var actions []Action
+	for floor := 0; floor < config.NUM_FLOORS; floor++ {
+		for btn := elevator.ButtonType(0); btn < config.NUM_BUTTONS; btn++ {
+			if value := lampValue(e, floor, btn); value != lampValue(old, floor, btn) {
+				actions = append(actions, Action{Type: SetButtonLamp, Button: btn, Floor: floor, Value: value})
 			}
 		}
-	default:
-		// No state change needed
-	}
-
-	return newState, resetDoorOpenTimer, stopDoorStuckTimer, resetDoorStuckTimer
-}
-
-// func OnDoorTimeout(doorOpenTimer *time.Timer, doorStuckTimer *time.Timer) {
-// 	switch elev.Behavior {
-// 	case elevator.DoorOpen:
-// 		if elev.IsObstructed {
-// 			doorOpenTimer.Reset(config.DOOR_OPEN_DURATION)
-// 		} else {
-// 			// stop the doorStuckTimer!
-// 			doorStuckTimer.Stop()
-// 			elevator.SetDoorOpenLamp(false)
-
-// 			pair := elevator.RequestsChooseDirection(elev)
-// 			elev.Dir = pair.Dir
-// 			elev.Behavior = pair.Behavior
-
-// 			// if pair.Behavior == elevator.Moving {
-// 			// 	elevator.SetMotorDirection(elev.Dir)
-// 			// }
-
-// 			switch elev.Behavior {
-// 			case elevator.DoorOpen:
-// 				doorOpenTimer.Reset(config.DOOR_OPEN_DURATION)
-// 				doorStuckTimer.Reset(config.DOOR_STUCK_DURATION)
-
-// 				updatedElev, _ := elevator.RequestsClearAtCurrentFloor(elev)
-// 				elev = updatedElev
-
-// 				elevator.SetAllLights(&elev)
-// 			case elevator.Moving, elevator.Idle:
-// 				elevator.SetDoorOpenLamp(false)
-// 				elevator.SetMotorDirection(elev.Dir)
-// 			}
-// 		}
-// 	default:
-// 	}
-// }
+	}
+	return actions
+}
+
+func allLampActions(e elevator.Elevator) []Action {
+	var actions []Action
+	for floor := 0; floor < config.NUM_FLOORS; floor++ {
+		for btn := elevator.ButtonType(0); btn < config.NUM_BUTTONS; btn++ {
+			actions = append(actions, Action{Type: SetButtonLamp, Button: btn, Floor: floor, Value: lampValue(e, floor, btn)})
+		}
+	}
+	return actions
+}
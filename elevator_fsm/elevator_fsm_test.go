@@ -0,0 +1,334 @@
+package elevator_fsm
+
+import (
+	"elev/elevator"
+	"reflect"
+	"testing"
+)
+
+// car returns an elevator in normal service at floor with the given behavior and direction
+func car(floor int, behavior elevator.ElevatorBehavior, dir elevator.MotorDirection) elevator.Elevator {
+	e := elevator.NewElevator()
+	e.Floor = floor
+	e.Behavior = behavior
+	e.Dir = dir
+	return e
+}
+
+// with returns e changed by change, so a test case can list the state before and after side by side
+func with(e elevator.Elevator, change func(e *elevator.Elevator)) elevator.Elevator {
+	change(&e)
+	return e
+}
+
+func TestTransition(t *testing.T) {
+	tests := []struct {
+		name        string
+		before      elevator.Elevator
+		event       Event
+		wantAfter   elevator.Elevator
+		wantActions []Action
+	}{
+		{
+			name: "arrival at a floor with a cab request stops and opens the door",
+			before: with(car(1, elevator.Moving, elevator.DirectionUp), func(e *elevator.Elevator) {
+				e.Requests[2][elevator.ButtonCab] = true
+			}),
+			event:     Event{Type: FloorArrivalEvent, Floor: 2},
+			wantAfter: car(2, elevator.DoorOpen, elevator.DirectionUp),
+			wantActions: []Action{
+				{Type: SetFloorIndicator, Floor: 2},
+				{Type: SetMotorDirection, Dir: elevator.DirectionStop},
+				{Type: SetDoorOpenLamp, Value: true},
+				{Type: StartDoorTimer},
+				{Type: StartDoorStuckTimer},
+				{Type: SetButtonLamp, Floor: 2, Button: elevator.ButtonCab, Value: false},
+			},
+		},
+		{
+			name: "arrival at a floor with a hall request in the direction of travel clears it",
+			before: with(car(0, elevator.Moving, elevator.DirectionUp), func(e *elevator.Elevator) {
+				e.Requests[1][elevator.ButtonHallUp] = true
+				e.Requests[3][elevator.ButtonCab] = true
+			}),
+			event: Event{Type: FloorArrivalEvent, Floor: 1},
+			wantAfter: with(car(1, elevator.DoorOpen, elevator.DirectionUp), func(e *elevator.Elevator) {
+				e.Requests[3][elevator.ButtonCab] = true
+			}),
+			wantActions: []Action{
+				{Type: SetFloorIndicator, Floor: 1},
+				{Type: SetMotorDirection, Dir: elevator.DirectionStop},
+				{Type: SetDoorOpenLamp, Value: true},
+				{Type: StartDoorTimer},
+				{Type: StartDoorStuckTimer},
+				{Type: HallRequestCleared, Floor: 1, Button: elevator.ButtonHallUp},
+			},
+		},
+		{
+			name: "arrival at a floor without requests passes it",
+			before: with(car(0, elevator.Moving, elevator.DirectionUp), func(e *elevator.Elevator) {
+				e.Requests[3][elevator.ButtonCab] = true
+			}),
+			event: Event{Type: FloorArrivalEvent, Floor: 1},
+			wantAfter: with(car(1, elevator.Moving, elevator.DirectionUp), func(e *elevator.Elevator) {
+				e.Requests[3][elevator.ButtonCab] = true
+			}),
+			wantActions: []Action{{Type: SetFloorIndicator, Floor: 1}},
+		},
+		{
+			name:      "door timeout without requests closes the door and idles",
+			before:    car(1, elevator.DoorOpen, elevator.DirectionUp),
+			event:     Event{Type: DoorTimeoutEvent},
+			wantAfter: car(1, elevator.Idle, elevator.DirectionStop),
+			wantActions: []Action{
+				{Type: SetDoorOpenLamp, Value: false},
+				{Type: StopDoorStuckTimer},
+			},
+		},
+		{
+			name: "door timeout with a request below closes the door and moves",
+			before: with(car(2, elevator.DoorOpen, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.Requests[0][elevator.ButtonCab] = true
+			}),
+			event: Event{Type: DoorTimeoutEvent},
+			wantAfter: with(car(2, elevator.Moving, elevator.DirectionDown), func(e *elevator.Elevator) {
+				e.Requests[0][elevator.ButtonCab] = true
+			}),
+			wantActions: []Action{
+				{Type: SetDoorOpenLamp, Value: false},
+				{Type: StopDoorStuckTimer},
+				{Type: SetMotorDirection, Dir: elevator.DirectionDown},
+			},
+		},
+		{
+			name: "door timeout while obstructed keeps the door open",
+			before: with(car(1, elevator.DoorOpen, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.IsObstructed = true
+				e.Requests[3][elevator.ButtonCab] = true
+			}),
+			event: Event{Type: DoorTimeoutEvent},
+			wantAfter: with(car(1, elevator.DoorOpen, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.IsObstructed = true
+				e.Requests[3][elevator.ButtonCab] = true
+			}),
+			wantActions: []Action{{Type: StartDoorTimer}},
+		},
+		{
+			name: "door timeout while stopped keeps the door open",
+			before: with(car(1, elevator.DoorOpen, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.IsStopped = true
+			}),
+			event: Event{Type: DoorTimeoutEvent},
+			wantAfter: with(car(1, elevator.DoorOpen, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.IsStopped = true
+			}),
+		},
+		{
+			name:   "stop press between floors halts the car",
+			before: car(1, elevator.Moving, elevator.DirectionUp),
+			event:  Event{Type: StopButtonEvent, Floor: -1},
+			wantAfter: with(car(1, elevator.Idle, elevator.DirectionUp), func(e *elevator.Elevator) {
+				e.IsStopped = true
+			}),
+			wantActions: []Action{
+				{Type: SetMotorDirection, Dir: elevator.DirectionStop},
+				{Type: SetStopLamp, Value: true},
+				{Type: StopDoorTimer},
+				{Type: StopDoorStuckTimer},
+			},
+		},
+		{
+			name:   "stop press at a floor halts the car and opens the door",
+			before: car(2, elevator.Moving, elevator.DirectionUp),
+			event:  Event{Type: StopButtonEvent, Floor: 2},
+			wantAfter: with(car(2, elevator.DoorOpen, elevator.DirectionUp), func(e *elevator.Elevator) {
+				e.IsStopped = true
+			}),
+			wantActions: []Action{
+				{Type: SetMotorDirection, Dir: elevator.DirectionStop},
+				{Type: SetStopLamp, Value: true},
+				{Type: StopDoorTimer},
+				{Type: StopDoorStuckTimer},
+				{Type: SetFloorIndicator, Floor: 2},
+				{Type: SetDoorOpenLamp, Value: true},
+			},
+		},
+		{
+			name: "stop release at a floor lets the door close through the door timer",
+			before: with(car(2, elevator.DoorOpen, elevator.DirectionUp), func(e *elevator.Elevator) {
+				e.IsStopped = true
+			}),
+			event:     Event{Type: StopButtonEvent, Floor: 2},
+			wantAfter: car(2, elevator.DoorOpen, elevator.DirectionUp),
+			wantActions: []Action{
+				{Type: SetStopLamp, Value: false},
+				{Type: SetDoorOpenLamp, Value: true},
+				{Type: StartDoorTimer},
+				{Type: StartDoorStuckTimer},
+			},
+		},
+		{
+			name: "stop release between floors continues towards the requests",
+			before: with(car(1, elevator.Idle, elevator.DirectionUp), func(e *elevator.Elevator) {
+				e.IsStopped = true
+				e.Requests[3][elevator.ButtonCab] = true
+			}),
+			event: Event{Type: StopButtonEvent, Floor: -1},
+			wantAfter: with(car(1, elevator.Moving, elevator.DirectionUp), func(e *elevator.Elevator) {
+				e.Requests[3][elevator.ButtonCab] = true
+			}),
+			wantActions: []Action{
+				{Type: SetStopLamp, Value: false},
+				{Type: SetMotorDirection, Dir: elevator.DirectionUp},
+			},
+		},
+		{
+			name: "maintenance drops the hall requests and serves the cab requests",
+			before: with(car(2, elevator.Idle, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.Requests[3][elevator.ButtonHallDown] = true
+				e.Requests[0][elevator.ButtonCab] = true
+			}),
+			event: Event{Type: ServiceModeEvent, ServiceMode: elevator.MaintenanceService, Floor: 0},
+			wantAfter: with(car(2, elevator.Moving, elevator.DirectionDown), func(e *elevator.Elevator) {
+				e.ServiceMode = elevator.MaintenanceService
+				e.ParkFloor = 0
+				e.Requests[0][elevator.ButtonCab] = true
+			}),
+			wantActions: []Action{{Type: SetMotorDirection, Dir: elevator.DirectionDown}},
+		},
+		{
+			name:   "maintenance at the park floor holds the door open",
+			before: car(0, elevator.Idle, elevator.DirectionStop),
+			event:  Event{Type: ServiceModeEvent, ServiceMode: elevator.MaintenanceService, Floor: 0},
+			wantAfter: with(car(0, elevator.DoorOpen, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.ServiceMode = elevator.MaintenanceService
+				e.ParkFloor = 0
+			}),
+			wantActions: []Action{
+				{Type: SetDoorOpenLamp, Value: true},
+				{Type: StartDoorTimer},
+				{Type: StartDoorStuckTimer},
+			},
+		},
+		{
+			name: "door timeout with the door held open in maintenance stops the door stuck timer",
+			before: with(car(0, elevator.DoorOpen, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.ServiceMode = elevator.MaintenanceService
+				e.ParkFloor = 0
+			}),
+			event: Event{Type: DoorTimeoutEvent},
+			wantAfter: with(car(0, elevator.DoorOpen, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.ServiceMode = elevator.MaintenanceService
+				e.ParkFloor = 0
+			}),
+			wantActions: []Action{{Type: StopDoorStuckTimer}},
+		},
+		{
+			name: "back to normal service lets a held door close",
+			before: with(car(0, elevator.DoorOpen, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.ServiceMode = elevator.MaintenanceService
+				e.ParkFloor = 0
+			}),
+			event:       Event{Type: ServiceModeEvent, ServiceMode: elevator.NormalService, Floor: -1},
+			wantAfter:   car(0, elevator.DoorOpen, elevator.DirectionStop),
+			wantActions: []Action{{Type: StartDoorTimer}},
+		},
+		{
+			name: "fire recall drops every request and turns off the lamps",
+			before: with(car(2, elevator.Idle, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.Requests[3][elevator.ButtonCab] = true
+				e.Requests[1][elevator.ButtonHallUp] = true
+				e.HallLightStates[1][elevator.ButtonHallUp] = true
+			}),
+			event: Event{Type: ServiceModeEvent, ServiceMode: elevator.RecallService, Floor: 0},
+			wantAfter: with(car(2, elevator.Moving, elevator.DirectionDown), func(e *elevator.Elevator) {
+				e.ServiceMode = elevator.RecallService
+				e.ParkFloor = 0
+				e.HallLightStates[1][elevator.ButtonHallUp] = true
+			}),
+			wantActions: []Action{
+				{Type: SetMotorDirection, Dir: elevator.DirectionDown},
+				{Type: SetButtonLamp, Floor: 1, Button: elevator.ButtonHallUp, Value: false},
+				{Type: SetButtonLamp, Floor: 3, Button: elevator.ButtonCab, Value: false},
+			},
+		},
+		{
+			name: "requests are ignored during a fire recall",
+			before: with(car(0, elevator.DoorOpen, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.ServiceMode = elevator.RecallService
+				e.ParkFloor = 0
+			}),
+			event: Event{Type: RequestEvent, Floor: 2, Button: elevator.ButtonCab},
+			wantAfter: with(car(0, elevator.DoorOpen, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.ServiceMode = elevator.RecallService
+				e.ParkFloor = 0
+			}),
+		},
+		{
+			name: "independent service drops the hall requests and holds the door open",
+			before: with(car(1, elevator.Idle, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.Requests[2][elevator.ButtonHallUp] = true
+			}),
+			event: Event{Type: ServiceModeEvent, ServiceMode: elevator.IndependentService, Floor: -1},
+			wantAfter: with(car(1, elevator.DoorOpen, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.ServiceMode = elevator.IndependentService
+			}),
+			wantActions: []Action{
+				{Type: SetDoorOpenLamp, Value: true},
+				{Type: StartDoorTimer},
+				{Type: StartDoorStuckTimer},
+			},
+		},
+		{
+			name: "hall requests are ignored in independent service",
+			before: with(car(1, elevator.DoorOpen, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.ServiceMode = elevator.IndependentService
+			}),
+			event: Event{Type: RequestEvent, Floor: 3, Button: elevator.ButtonHallDown},
+			wantAfter: with(car(1, elevator.DoorOpen, elevator.DirectionStop), func(e *elevator.Elevator) {
+				e.ServiceMode = elevator.IndependentService
+			}),
+		},
+	}
+
+	fsm := FSM{}
+	for _, test := range tests {
+		after, actions := fsm.Transition(test.before, test.event)
+		if !reflect.DeepEqual(after, test.wantAfter) {
+			t.Errorf("%s:\n got state  %+v\n want state %+v", test.name, after, test.wantAfter)
+		}
+		if !reflect.DeepEqual(actions, test.wantActions) {
+			t.Errorf("%s:\n got actions  %+v\n want actions %+v", test.name, actions, test.wantActions)
+		}
+	}
+}
+
+func TestScanEndFloor(t *testing.T) {
+	fsm := FSM{Strategy: elevator.ScanStrategy{}}
+	before := with(car(2, elevator.Moving, elevator.DirectionUp), func(e *elevator.Elevator) {
+		e.Requests[0][elevator.ButtonCab] = true
+	})
+
+	// nobody asked for the top floor, so the sweep turns around there without a door cycle
+	after, actions := fsm.Transition(before, Event{Type: FloorArrivalEvent, Floor: 3})
+	wantAfter := with(car(3, elevator.Moving, elevator.DirectionDown), func(e *elevator.Elevator) {
+		e.Requests[0][elevator.ButtonCab] = true
+	})
+	wantActions := []Action{
+		{Type: SetFloorIndicator, Floor: 3},
+		{Type: SetMotorDirection, Dir: elevator.DirectionStop},
+		{Type: SetMotorDirection, Dir: elevator.DirectionDown},
+	}
+	if !reflect.DeepEqual(after, wantAfter) {
+		t.Errorf("got state  %+v\n want state %+v", after, wantAfter)
+	}
+	if !reflect.DeepEqual(actions, wantActions) {
+		t.Errorf("got actions  %+v\n want actions %+v", actions, wantActions)
+	}
+
+	// a request at the end floor still opens the door
+	before.Requests[3][elevator.ButtonCab] = true
+	if after, _ := fsm.Transition(before, Event{Type: FloorArrivalEvent, Floor: 3}); after.Behavior != elevator.DoorOpen {
+		t.Errorf("a cab call at the top floor: got %v, want the door open", after.Behavior)
+	}
+}
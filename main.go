@@ -1,6 +1,7 @@
 package main
 
 import (
+	"elev/elevator"
 	"elev/node"
 	"os"
 	"strconv"
@@ -14,8 +15,15 @@ func main() {
 	receiverPort, _ := strconv.Atoi(argsWithoutProg[2])
 	id, _ := strconv.Atoi(argsWithoutProg[3])
 
-	mainNode := node.MakeNode(id, elevPort, bcastPort, receiverPort)
+	// the node stays Inactive until the driver has connected to the elevator server
+	drv := elevator.NewTCPDriver(elevPort)
+
+	mainNode := node.MakeNode(id, drv, bcastPort, receiverPort)
 	mainNode.State = node.Inactive
+
+	// runtime commands such as "maintenance on" are read from the terminal
+	go node.OperatorConsole(os.Stdin, mainNode.OperatorCommandRx)
+
 	for {
 		switch mainNode.State {
 
@@ -31,6 +39,9 @@ func main() {
 		case node.Master:
 			mainNode.State = node.MasterProgram(mainNode)
 
+		case node.Maintenance:
+			mainNode.State = node.MaintenanceProgram(mainNode)
+
 		}
 
 	}
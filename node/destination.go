@@ -0,0 +1,105 @@
+package node
+
+import (
+	"elev/Network/messagehandler"
+	"elev/Network/messages"
+	"elev/config"
+	"elev/elevator"
+	"fmt"
+	"slices"
+)
+
+// makeDestinationRequest makes the request for a destination call entered at the panel of this node
+func (node *NodeData) makeDestinationRequest(origin int, destination int) (messages.NewDestinationRequest, error) {
+	callID, err := messagehandler.GenerateMessageID(messagehandler.DESTINATION_CALL)
+	if err != nil {
+		return messages.NewDestinationRequest{}, fmt.Errorf("generating a destination call id: %w", err)
+	}
+	return messages.NewDestinationRequest{CallID: callID, PanelNodeID: node.ID, Origin: origin, Destination: destination}, nil
+}
+
+// registerDestinationCall adds a new destination call on master, and the hall request it is picked up with.
+// Returns true if the hall requests should be distributed.
+func (node *NodeData) registerDestinationCall(req messages.NewDestinationRequest) bool {
+	if !config.DESTINATION_DISPATCH || node.RecallActive {
+		return false
+	}
+	if req.Origin < 0 || req.Origin >= config.NUM_FLOORS || req.Destination < 0 || req.Destination >= config.NUM_FLOORS ||
+		req.Origin == req.Destination {
+		fmt.Printf("Received an invalid destination call from floor %d to floor %d\n", req.Origin, req.Destination)
+		return false
+	}
+	for _, call := range node.DestinationCalls {
+		if call.CallID == req.CallID {
+			return false
+		}
+	}
+	if len(node.DestinationCalls) >= config.DESTINATION_MAX_CALLS {
+		fmt.Printf("Too many destination calls, dropping the call from floor %d to floor %d\n", req.Origin, req.Destination)
+		return false
+	}
+
+	call := messages.DestinationCall{
+		CallID:      req.CallID,
+		PanelNodeID: req.PanelNodeID,
+		Origin:      req.Origin,
+		Destination: req.Destination,
+		Car:         -1,
+	}
+	node.DestinationCalls = append(node.DestinationCalls, call)
+	node.GlobalHallRequests[call.Origin][call.HallButton()] = true
+
+	fmt.Printf("New destination call from floor %d to floor %d\n", call.Origin, call.Destination)
+	node.GlobalHallRequestTx <- messages.GlobalHallRequest{HallRequests: node.GlobalHallRequests}
+	node.DestinationAssignmentsTx <- messages.DestinationAssignments{Calls: node.DestinationCalls}
+	node.ElevLightAndAssignmentUpdateTx <- makeLightMessage(node.GlobalHallRequests)
+	return true
+}
+
+// removePickedUpCalls removes the destination calls picked up by the completed hall request.
+// Their destinations are now cab calls of the car that picked them up.
+func (node *NodeData) removePickedUpCalls(floor int, btn elevator.ButtonType) {
+	// a new slice, as the old one may still be read by the transmitter and the hall assigner
+	remaining := slices.DeleteFunc(slices.Clone(node.DestinationCalls), func(call messages.DestinationCall) bool {
+		return call.Origin == floor && call.HallButton() == btn
+	})
+	if len(remaining) != len(node.DestinationCalls) {
+		node.DestinationCalls = remaining
+		node.DestinationAssignmentsTx <- messages.DestinationAssignments{Calls: node.DestinationCalls}
+	}
+}
+
+// announceDestinationCalls tells the passengers at the panel of this node which car to take,
+// when their call is assigned and whenever it is moved to another car
+func (node *NodeData) announceDestinationCalls() {
+	waiting := make(map[uint64]bool)
+	for _, call := range node.DestinationCalls {
+		waiting[call.CallID] = true
+		if call.PanelNodeID != node.ID || call.Car < 0 {
+			continue
+		}
+		if car, ok := node.announcedCalls[call.CallID]; ok && car == call.Car {
+			continue
+		}
+		node.announcedCalls[call.CallID] = call.Car
+		fmt.Printf("Destination call from floor %d to floor %d: take car %d\n", call.Origin, call.Destination, call.Car)
+	}
+
+	// forget the calls that have been picked up
+	for callID := range node.announcedCalls {
+		if !waiting[callID] {
+			delete(node.announcedCalls, callID)
+		}
+	}
+}
+
+// destinationsOf returns the destinations of the passengers the car is to pick up, by [floor][up/down]
+func destinationsOf(calls []messages.DestinationCall, id int) [config.NUM_FLOORS][2][config.NUM_FLOORS]bool {
+	var destinations [config.NUM_FLOORS][2][config.NUM_FLOORS]bool
+	for _, call := range calls {
+		if call.Car == id {
+			destinations[call.Origin][call.HallButton()][call.Destination] = true
+		}
+	}
+	return destinations
+}
@@ -59,6 +59,7 @@ ForLoop:
 			decisionTimer.Reset(config.DISCONNECTED_DECISION_INTERVAL)
 
 		case elevMsg := <-node.ElevatorEventRx:
+			node.faults.update(elevMsg)
 			switch elevMsg.EventType {
 
 			case singleelevator.DoorStuckEvent:
@@ -67,6 +68,26 @@ ForLoop:
 					break ForLoop
 				}
 
+			case singleelevator.MotorFaultEvent:
+				// the car cannot reach its hall assignments, go inactive so they are reassigned
+				if elevMsg.HasMotorFault {
+					nextNodeState = Inactive
+					break ForLoop
+				}
+
+			case singleelevator.EmergencyStopEvent:
+				// withdraw from hall assignment until the emergency stop is released
+				if elevMsg.IsStopped {
+					nextNodeState = Inactive
+					break ForLoop
+				}
+
+			case singleelevator.ServerConnectionEvent:
+				if !elevMsg.ServerIsConnected {
+					nextNodeState = Inactive
+					break ForLoop
+				}
+
 			case singleelevator.HallButtonEvent:
 				// ignore hall button presses
 
@@ -86,9 +107,20 @@ ForLoop:
 			}
 			nextNodeState = Slave
 			break ForLoop
+		case command := <-node.OperatorCommandRx:
+			if node.handleOperatorCommand(command) {
+				nextNodeState = Maintenance
+				break ForLoop
+			}
+
+		case recallCommand := <-node.RecallCommandRx:
+			node.handleRecallCommand(recallCommand)
+
 		case <-node.HallAssignmentsRx:
 		case <-node.NodeElevStateUpdate:
 		case <-node.NewHallReqRx:
+		case <-node.NewDestinationReqRx:
+		case <-node.DestinationAssignmentsRx:
 		case <-node.HallAssignmentCompleteRx:
 		case <-node.NetworkEventRx:
 		case <-node.GlobalHallRequestRx:
@@ -0,0 +1,57 @@
+package node
+
+import (
+	"elev/singleelevator"
+	"strings"
+)
+
+// elevatorFaults are the faults last reported by our elevator. They are kept on the node across its states,
+// so the node only goes back into service when all of them are cleared.
+type elevatorFaults struct {
+	doorIsStuck       bool
+	hasMotorFault     bool
+	isStopped         bool
+	serverIsConnected bool
+}
+
+// update records the fault status carried by a fault event. It returns false for other events.
+func (faults *elevatorFaults) update(elevMsg singleelevator.ElevatorEvent) bool {
+	switch elevMsg.EventType {
+	case singleelevator.DoorStuckEvent:
+		faults.doorIsStuck = elevMsg.DoorIsStuck
+	case singleelevator.MotorFaultEvent:
+		faults.hasMotorFault = elevMsg.HasMotorFault
+	case singleelevator.EmergencyStopEvent:
+		faults.isStopped = elevMsg.IsStopped
+	case singleelevator.ServerConnectionEvent:
+		faults.serverIsConnected = elevMsg.ServerIsConnected
+	default:
+		return false
+	}
+	return true
+}
+
+// active returns whether any fault keeps the car out of service
+func (faults elevatorFaults) active() bool {
+	return faults.doorIsStuck || faults.hasMotorFault || faults.isStopped || !faults.serverIsConnected
+}
+
+func (faults elevatorFaults) String() string {
+	var active []string
+	if faults.doorIsStuck {
+		active = append(active, "door stuck")
+	}
+	if faults.hasMotorFault {
+		active = append(active, "motor fault")
+	}
+	if faults.isStopped {
+		active = append(active, "emergency stop")
+	}
+	if !faults.serverIsConnected {
+		active = append(active, "no elevator server")
+	}
+	if len(active) == 0 {
+		return "no faults"
+	}
+	return strings.Join(active, ", ")
+}
@@ -1,7 +1,6 @@
 package node
 
 import (
-	"elev/singleelevator"
 	"fmt"
 )
 
@@ -13,18 +12,32 @@ ForLoop:
 		select {
 
 		case elevMsg := <-node.ElevatorEventRx:
-			// check whether the door is not stuck
-			if !elevMsg.DoorIsStuck && elevMsg.EventType == singleelevator.DoorStuckEvent {
-				nextNodeState = Disconnected
+			// go back into service only when every fault has been cleared
+			if node.faults.update(elevMsg) {
+				if !node.faults.active() {
+					nextNodeState = Disconnected
+					break ForLoop
+				}
+				fmt.Printf("Node %d stays Inactive: %v\n", node.ID, node.faults)
+			}
+
+		case command := <-node.OperatorCommandRx:
+			if node.handleOperatorCommand(command) {
+				nextNodeState = Maintenance
 				break ForLoop
 			}
 
+		case recallCommand := <-node.RecallCommandRx:
+			node.handleRecallCommand(recallCommand)
+
 		case <-node.HallAssignmentsRx:
 		case <-node.CabRequestInfoRx:
 		case <-node.GlobalHallRequestRx:
 		case <-node.ConnectionReqRx:
 		case <-node.NodeElevStateUpdate:
 		case <-node.NewHallReqRx:
+		case <-node.NewDestinationReqRx:
+		case <-node.DestinationAssignmentsRx:
 		case <-node.HallAssignmentCompleteRx:
 		case <-node.NetworkEventRx:
 		case <-node.MyElevStatesRx:
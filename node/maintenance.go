@@ -0,0 +1,83 @@
+package node
+
+import (
+	"elev/Network/messages"
+	"elev/elevator"
+	"elev/singleelevator"
+	"fmt"
+)
+
+// MaintenanceProgram keeps the car out of service until the operator puts it back.
+// The car finishes its cab requests and parks with the door open. Its states are still broadcast,
+// marked as in maintenance, so the master stops counting it as active and hands its hall assignments to the others.
+func MaintenanceProgram(node *NodeData) nodestate {
+	fmt.Printf("Node %d is now in Maintenance, parking at floor %d\n", node.ID, node.MaintenanceParkFloor)
+	var nextNodeState nodestate
+
+	node.ElevLightAndAssignmentUpdateTx <- node.makeServiceModeMessage(elevator.MaintenanceService, node.MaintenanceParkFloor)
+
+ForLoop:
+	for {
+		select {
+		case command := <-node.OperatorCommandRx:
+			switch command.Type {
+			case MaintenanceOnCommand:
+				node.MaintenanceParkFloor = command.Floor
+				node.ElevLightAndAssignmentUpdateTx <- node.makeServiceModeMessage(elevator.MaintenanceService, node.MaintenanceParkFloor)
+			case MaintenanceOffCommand:
+				node.ElevLightAndAssignmentUpdateTx <- node.makeServiceModeMessage(node.inServiceMode(), -1)
+				// faults from before and during maintenance decide where we go when leaving
+				if node.faults.active() {
+					nextNodeState = Inactive
+				} else {
+					nextNodeState = Disconnected
+				}
+				break ForLoop
+			default:
+				node.handleOperatorCommand(command)
+			}
+
+		case recallCommand := <-node.RecallCommandRx:
+			node.handleRecallCommand(recallCommand)
+
+		case destinationAssignments := <-node.DestinationAssignmentsRx:
+			node.DestinationCalls = destinationAssignments.Calls
+			node.announceDestinationCalls()
+
+		case elevMsg := <-node.ElevatorEventRx:
+			node.faults.update(elevMsg)
+			switch elevMsg.EventType {
+			case singleelevator.HallButtonEvent:
+				// we do not serve hall requests, but the master should know about them
+				node.NewHallReqTx <- messages.NewHallRequest{
+					Floor:      elevMsg.ButtonEvent.Floor,
+					HallButton: elevMsg.ButtonEvent.Button,
+				}
+			}
+
+		case myElevStates := <-node.MyElevStatesRx:
+			// keep broadcasting so the master knows we are in maintenance and not dead
+			node.NodeElevStatesTx <- messages.NodeElevState{
+				NodeID:    node.ID,
+				ElevState: myElevStates,
+				Protocol:  messages.OwnProtocol(),
+			}
+
+		case newGlobalHallReq := <-node.GlobalHallRequestRx:
+			if hasChanged(newGlobalHallReq.HallRequests, node.GlobalHallRequests) {
+				node.GlobalHallRequests = newGlobalHallReq.HallRequests
+				node.ElevLightAndAssignmentUpdateTx <- makeLightMessage(newGlobalHallReq.HallRequests)
+			}
+
+		case <-node.HallAssignmentsRx:
+		case <-node.CabRequestInfoRx:
+		case <-node.ConnectionReqRx:
+		case <-node.NodeElevStateUpdate:
+		case <-node.NewHallReqRx:
+		case <-node.NewDestinationReqRx:
+		case <-node.HallAssignmentCompleteRx:
+		case <-node.NetworkEventRx:
+		}
+	}
+	return nextNodeState
+}
@@ -4,10 +4,11 @@ import (
 	"elev/Network/messagehandler"
 	"elev/Network/messages"
 	"elev/config"
-	"elev/costFNS/hallRequestAssigner"
+	"elev/costFNS/assigner"
 	"elev/elevator"
 	"elev/singleelevator"
 	"fmt"
+	"sort"
 	"time"
 )
 
@@ -70,13 +71,26 @@ func MasterProgram(node *NodeData) nodestate {
 	recentHACompleteBuffer := makeNewMessageIDBuffer(bufferSize)
 	var nextNodeState nodestate
 
+	// starvation guard, hall requests that wait too long are escalated
+	var waits hallRequestWaits
+	waits.update(node.GlobalHallRequests, time.Now())
+	slaTicker := time.NewTicker(config.HALL_REQUEST_SLA_CHECK_INTERVAL)
+	defer slaTicker.Stop()
+
 	// inform the global hall request transmitter of the new global hall requests
 	fmt.Printf("Initiating master: Global requests: %v\n", node.GlobalHallRequests)
 	node.GlobalHallRequestTx <- messages.GlobalHallRequest{HallRequests: node.GlobalHallRequests}
 	node.ElevLightAndAssignmentUpdateTx <- makeLightMessage(node.GlobalHallRequests)
 
+	// a new master carries on a fire recall it is part of
+	node.RecallCommandTx <- messages.RecallCommand{Active: node.RecallActive, Floor: node.RecallFloor}
+	// and the destination calls it knows of, the passengers have been told which car to take
+	node.DestinationAssignmentsTx <- messages.DestinationAssignments{Calls: node.DestinationCalls}
+
 	// start the transmitters
 	node.GlobalHallReqTransmitEnableTx <- true
+	node.RecallCommandTransmitEnableTx <- true
+	node.DestinationAssignmentsTransmitEnableTx <- true
 	node.HallRequestAssignerTransmitEnableTx <- true
 	node.commandToServerTx <- "startConnectionTimeoutDetection"
 
@@ -85,6 +99,7 @@ ForLoop:
 	Select:
 		select {
 		case elevMsg := <-node.ElevatorEventRx:
+			node.faults.update(elevMsg)
 			switch elevMsg.EventType {
 
 			case singleelevator.DoorStuckEvent:
@@ -97,6 +112,36 @@ ForLoop:
 
 				break Select
 
+			case singleelevator.MotorFaultEvent:
+				fmt.Println("MotorFaultEvent")
+				// the car cannot reach its hall assignments, go inactive so they are reassigned
+				if elevMsg.HasMotorFault {
+					nextNodeState = Inactive
+					break ForLoop
+				}
+
+				break Select
+
+			case singleelevator.EmergencyStopEvent:
+				fmt.Println("EmergencyStopEvent")
+				// withdraw from hall assignment until the emergency stop is released
+				if elevMsg.IsStopped {
+					nextNodeState = Inactive
+					break ForLoop
+				}
+
+				break Select
+
+			case singleelevator.ServerConnectionEvent:
+				fmt.Println("ServerConnectionEvent")
+				// without the elevator server we cannot serve our hall assignments, go inactive so they are handed off
+				if !elevMsg.ServerIsConnected {
+					nextNodeState = Inactive
+					break ForLoop
+				}
+
+				break Select
+
 			case singleelevator.HallButtonEvent:
 				fmt.Printf("HallButtonEvent\n")
 				// new hallbuttonpress from my elevator
@@ -110,6 +155,8 @@ ForLoop:
 				// update the global hall assignments
 				if elevMsg.ButtonEvent.Button != elevator.ButtonCab {
 					node.GlobalHallRequests[elevMsg.ButtonEvent.Floor][elevMsg.ButtonEvent.Button] = false
+					node.removePickedUpCalls(elevMsg.ButtonEvent.Floor, elevMsg.ButtonEvent.Button)
+					node.announceDestinationCalls()
 				}
 			}
 
@@ -125,11 +172,25 @@ ForLoop:
 			node.ElevLightAndAssignmentUpdateTx <- makeLightMessage(node.GlobalHallRequests)
 
 		case myStates := <-node.MyElevStatesRx:
+			// our own car takes hall assignments only in normal service and while healthy, redistribute when that changes
+			if myStates.ServiceMode != myElevState.ElevState.ServiceMode {
+				fmt.Printf("Node %d is now in %s service\n", node.ID, myStates.ServiceMode.String())
+				shouldDistributeHallRequests = true
+				node.commandToServerTx <- "getActiveElevStates"
+			} else if myStates.Health.Healthy() != myElevState.ElevState.Health.Healthy() {
+				fmt.Printf("Node %d is now %s\n", node.ID, myStates.Health.String())
+				shouldDistributeHallRequests = true
+				node.commandToServerTx <- "getActiveElevStates"
+			}
 			// transmit elevator states to network
-			myElevState = messages.NodeElevState{NodeID: node.ID, ElevState: myStates}
+			myElevState = messages.NodeElevState{NodeID: node.ID, ElevState: myStates, Protocol: messages.OwnProtocol()}
 			node.NodeElevStatesTx <- myElevState
 
 		case newHallReq := <-node.NewHallReqRx:
+			// hall calls are cancelled during a fire recall
+			if node.RecallActive {
+				break Select
+			}
 
 			updatedState, shouldDistribute := ProcessNewHallRequest(node.GlobalHallRequests, newHallReq)
 			shouldDistributeHallRequests = shouldDistribute
@@ -154,24 +215,43 @@ ForLoop:
 				node.commandToServerTx <- "getActiveElevStates"
 			}
 
+		case destinationReq := <-node.NewDestinationReqRx:
+			if node.registerDestinationCall(destinationReq) {
+				shouldDistributeHallRequests = true
+				if time.Since(lastStateRequest) > config.STATE_REQUEST_INTERVAL {
+					lastStateRequest = time.Now()
+					node.commandToServerTx <- "getActiveElevStates"
+				}
+			}
+
 		case elevStatesUpdate := <-node.NodeElevStateUpdate:
 			fmt.Printf("Received new elevator states update: %v\n", elevStatesUpdate)
 			// compute the hall assignments
-			result, newShouldDistribute := ComputeHallAssignments(shouldDistributeHallRequests,
+			node.HallDestinations.SetCalls(node.DestinationCalls)
+			result, newShouldDistribute := ComputeHallAssignments(node.HallAssigner,
+				shouldDistributeHallRequests,
 				elevStatesUpdate,
 				myElevState,
 				node.GlobalHallRequests,
+				node.DestinationCalls,
 				activeConnReq)
-			// send the hall assignments to the hall assignment transmitter
-			node.ElevLightAndAssignmentUpdateTx <- result.MyAssignment
-
+			// the unhealthy cars are relieved even if the hall requests could not be distributed
+			if result.Assigned || result.Relieved {
+				node.ElevLightAndAssignmentUpdateTx <- result.MyAssignment
+			}
 			for _, assignment := range result.OtherAssignments {
 				node.HallAssignmentTx <- assignment
 			}
+			// the healthy cars keep their current hall assignments unless new ones were made
+			if result.Assigned {
+				// send the global hall requests to the server for broadcast to update other nodes
+				node.GlobalHallRequestTx <- result.GlobalHallRequest
 
-			// send the global hall requests to the server for broadcast to update other nodes
-
-			node.GlobalHallRequestTx <- result.GlobalHallRequest
+				// tell the passengers which car to take
+				node.DestinationCalls = result.DestinationCalls
+				node.DestinationAssignmentsTx <- messages.DestinationAssignments{Calls: node.DestinationCalls}
+				node.announceDestinationCalls()
+			}
 
 			node.ElevLightAndAssignmentUpdateTx <- makeLightMessage(node.GlobalHallRequests)
 
@@ -182,6 +262,15 @@ ForLoop:
 				delete(activeConnReq, cabReqConnReqAnswer.ReceiverNodeID)
 			}
 
+		case now := <-slaTicker.C:
+			escalated := waits.update(node.GlobalHallRequests, now)
+			node.HallEscalator.Escalate(waits.escalated)
+			if escalated {
+				shouldDistributeHallRequests = true
+				lastStateRequest = now
+				node.commandToServerTx <- "getActiveElevStates"
+			}
+
 		case connReq := <-node.ConnectionReqRx:
 			if connReq.NodeID != node.ID {
 				activeConnReq[connReq.NodeID] = connReq
@@ -195,6 +284,10 @@ ForLoop:
 				ProcessHAComplete(node.GlobalHallRequests, recentHACompleteBuffer, HA)
 
 			if updateNeeded {
+				if HA.HallButton != elevator.ButtonCab {
+					node.removePickedUpCalls(HA.Floor, HA.HallButton)
+					node.announceDestinationCalls()
+				}
 				fmt.Println("Received new hall assignment complete message")
 				fmt.Printf("Global hall requests after completion: %v\n", node.GlobalHallRequests)
 				// send the global hall requests to the server for broadcast to update other nodes
@@ -219,8 +312,35 @@ ForLoop:
 				fmt.Println("Node connected or disconnected, starting redistribution of hall requests")
 				shouldDistributeHallRequests = true
 				node.commandToServerTx <- "getActiveElevStates"
+
+			} else if networkEvent == messagehandler.NodeHealthChanged {
+				fmt.Println("Node health changed, starting redistribution of hall requests")
+				shouldDistributeHallRequests = true
+				node.commandToServerTx <- "getActiveElevStates"
 			}
 
+		case command := <-node.OperatorCommandRx:
+			// destination calls entered at our own panel are registered directly
+			if command.Type == DestinationCallCommand && config.DESTINATION_DISPATCH {
+				req, err := node.makeDestinationRequest(command.Floor, command.Destination)
+				if err != nil {
+					fmt.Printf("Fatal error: %v\n", err)
+					break Select
+				}
+				if node.registerDestinationCall(req) {
+					shouldDistributeHallRequests = true
+					node.commandToServerTx <- "getActiveElevStates"
+				}
+				break Select
+			}
+			if node.handleOperatorCommand(command) {
+				nextNodeState = Maintenance
+				break ForLoop
+			}
+
+		case <-node.RecallCommandRx:
+		case <-node.DestinationAssignmentsRx:
+
 		case <-node.HallAssignmentsRx:
 		case <-node.CabRequestInfoRx:
 		case <-node.GlobalHallRequestRx:
@@ -231,6 +351,9 @@ ForLoop:
 	// stop transmitters
 	node.GlobalHallReqTransmitEnableTx <- false
 	node.HallRequestAssignerTransmitEnableTx <- false
+	node.RecallCommandTransmitEnableTx <- false
+	node.DestinationAssignmentsTransmitEnableTx <- false
+	node.HallEscalator.Escalate([config.NUM_FLOORS][2]bool{})
 	node.commandToServerTx <- "stopConnectionTimeoutDetection"
 	node.TOLC = time.Now()
 	fmt.Printf("Exiting master, setting TOLC to %v\n", node.TOLC)
@@ -243,29 +366,66 @@ type HallAssignmentResult struct {
 	OtherAssignments  map[int]messages.NewHallAssignments
 	GlobalHallRequest messages.GlobalHallRequest
 	CabRequests       map[int]messages.CabRequestInfo
+	DestinationCalls  []messages.DestinationCall // the destination calls with their cars
+	Assigned          bool                       // the hall requests were distributed, the other fields are only set if so
+	Relieved          bool                       // we are unhealthy, MyAssignment relieves us of our hall requests even if they were not distributed
 }
 
-func ComputeHallAssignments(shouldDistribute bool,
+func ComputeHallAssignments(hallAssigner assigner.Assigner,
+	shouldDistribute bool,
 	elevStatesUpdate messagehandler.ElevStateUpdate,
 	myElevState messages.NodeElevState,
 	globalHallRequests [config.NUM_FLOORS][2]bool,
+	destinationCalls []messages.DestinationCall,
 	activeConnReq map[int]messages.ConnectionReq) (HallAssignmentResult, bool) {
 	var result HallAssignmentResult
+	result.DestinationCalls = destinationCalls
 	// if we should distribute, we run the hall request assigner algorithm
 	if shouldDistribute && elevStatesUpdate.OnlyActiveNodes {
-		// run the hall request assigner algorithm
-		elevStatesUpdate.NodeElevStatesMap[myElevState.NodeID] = myElevState.ElevState
-		hraOutput := hallRequestAssigner.HRAalgorithm(elevStatesUpdate.NodeElevStatesMap, globalHallRequests)
-		fmt.Printf("Hall request assigner output: %v\n", hraOutput)
+		// run the hall assigner
+		// the active elevators are all in normal service, and so must we be to take hall assignments
+		if myElevState.ElevState.ServiceMode == elevator.NormalService {
+			elevStatesUpdate.NodeElevStatesMap[myElevState.NodeID] = myElevState.ElevState
+		}
+		healthyStates, unhealthyIDs := splitByHealth(elevStatesUpdate.NodeElevStatesMap)
+		// the unhealthy cars are relieved of their hall requests and park where they are,
+		// also when there is no healthy car to take them over
 		result.OtherAssignments = make(map[int]messages.NewHallAssignments)
+		for _, id := range unhealthyIDs {
+			if id == myElevState.NodeID {
+				result.MyAssignment = makeHallAssignmentAndLightMessage([config.NUM_FLOORS][2]bool{}, globalHallRequests,
+					-1, [config.NUM_FLOORS][2][config.NUM_FLOORS]bool{})
+				result.Relieved = true
+			} else {
+				result.OtherAssignments[id] = messages.NewHallAssignments{NodeID: id, HomeFloor: -1, MessageID: 0}
+			}
+		}
+		hraOutput, err := hallAssigner.Assign(healthyStates, globalHallRequests)
+		if err != nil {
+			// the healthy cars keep their current assignments, try again on the next update
+			fmt.Printf("Hall assigner error: %v\n", err)
+			return result, shouldDistribute
+		}
+		result.Assigned = true
+		// the passengers take the car that was given the hall request of their call
+		calls := assigner.CarsOfCalls(destinationCalls, hraOutput)
+		result.DestinationCalls = calls
+		fmt.Printf("Hall request assigner output: %v\n", hraOutput)
+		homeFloors := assignHomeFloors(healthyStates)
+		if !result.Relieved {
+			// we take no hall requests unless the assigner gives us some
+			result.MyAssignment = makeHallAssignmentAndLightMessage([config.NUM_FLOORS][2]bool{}, globalHallRequests,
+				config.PARKING_HOME_FLOORS()[0], [config.NUM_FLOORS][2][config.NUM_FLOORS]bool{})
+		}
 		// fmt.Printf("Hall request assigner output: %v\n", hraOutput)
 		// make the hall assignments for all nodes
 		for id, hallRequests := range hraOutput {
 			// if the assignment is for me, we make the light and assignment message
 			if id == myElevState.NodeID {
-				result.MyAssignment = makeHallAssignmentAndLightMessage(hallRequests, globalHallRequests)
+				result.MyAssignment = makeHallAssignmentAndLightMessage(hallRequests, globalHallRequests, homeFloors[id], destinationsOf(calls, id))
 			} else { // if the assignment is for another node, we make a new hall assignment message
-				result.OtherAssignments[id] = messages.NewHallAssignments{NodeID: id, HallAssignment: hallRequests, MessageID: 0}
+				result.OtherAssignments[id] = messages.NewHallAssignments{NodeID: id, HallAssignment: hallRequests, HomeFloor: homeFloors[id],
+					Destinations: destinationsOf(calls, id), MessageID: 0}
 			}
 		}
 		// make the global hall request message
@@ -294,6 +454,37 @@ func ComputeHallAssignments(shouldDistribute bool,
 	return result, shouldDistribute
 }
 
+// splitByHealth returns the states of the healthy cars and the ids of the unhealthy ones
+func splitByHealth(elevStates map[int]elevator.ElevatorState) (map[int]elevator.ElevatorState, []int) {
+	healthyStates := make(map[int]elevator.ElevatorState, len(elevStates))
+	var unhealthyIDs []int
+	for id, state := range elevStates {
+		if state.Health.Healthy() {
+			healthyStates[id] = state
+		} else {
+			unhealthyIDs = append(unhealthyIDs, id)
+		}
+	}
+	return healthyStates, unhealthyIDs
+}
+
+// assignHomeFloors spreads the home floors over the active elevators in order of node id.
+// With more elevators than home floors, the home floors are reused from the start.
+func assignHomeFloors(elevStates map[int]elevator.ElevatorState) map[int]int {
+	ids := make([]int, 0, len(elevStates))
+	for id := range elevStates {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	parkingFloors := config.PARKING_HOME_FLOORS()
+	homeFloors := make(map[int]int, len(ids))
+	for i, id := range ids {
+		homeFloors[id] = parkingFloors[i%len(parkingFloors)]
+	}
+	return homeFloors
+}
+
 func ProcessHAComplete(
 	globalHallRequests [config.NUM_FLOORS][2]bool,
 	buffer MessageIDBuffer,
@@ -0,0 +1,118 @@
+package node
+
+import (
+	"elev/Network/messagehandler"
+	"elev/Network/messages"
+	"elev/config"
+	"elev/costFNS/assigner"
+	"elev/elevator"
+	"sort"
+	"testing"
+)
+
+func healthyCar(floor int) elevator.ElevatorState {
+	return elevator.ElevatorState{Floor: floor, Direction: elevator.DirectionStop, Behavior: elevator.Idle}
+}
+
+func unhealthyCar(floor int, health elevator.ElevatorHealth) elevator.ElevatorState {
+	state := healthyCar(floor)
+	state.Health = health
+	return state
+}
+
+func TestSplitByHealth(t *testing.T) {
+	healthyStates, unhealthyIDs := splitByHealth(map[int]elevator.ElevatorState{
+		1: healthyCar(0),
+		2: unhealthyCar(1, elevator.ElevatorHealth{DoorStuck: true}),
+		3: healthyCar(2),
+		4: unhealthyCar(3, elevator.ElevatorHealth{Maintenance: true}),
+	})
+	sort.Ints(unhealthyIDs)
+	if len(healthyStates) != 2 || healthyStates[1] != healthyCar(0) || healthyStates[3] != healthyCar(2) {
+		t.Errorf("got healthy cars %v, want 1 and 3", healthyStates)
+	}
+	if len(unhealthyIDs) != 2 || unhealthyIDs[0] != 2 || unhealthyIDs[1] != 4 {
+		t.Errorf("got unhealthy cars %v, want [2 4]", unhealthyIDs)
+	}
+}
+
+func TestComputeHallAssignmentsRelief(t *testing.T) {
+	const myID = 1
+	var hallRequests [config.NUM_FLOORS][2]bool
+	hallRequests[2][0] = true
+	stuck := elevator.ElevatorHealth{DoorStuck: true}
+
+	tests := []struct {
+		name         string
+		mine         elevator.ElevatorState
+		others       map[int]elevator.ElevatorState
+		wantAssigned bool
+		wantRelieved bool
+		wantRelief   []int // the other cars sent an empty assignment to park where they are
+		wantOwner    int   // the car given the hall request, -1 for none
+	}{
+		{
+			name:         "the healthy cars take the hall requests of the unhealthy",
+			mine:         healthyCar(0),
+			others:       map[int]elevator.ElevatorState{2: unhealthyCar(2, stuck)},
+			wantAssigned: true,
+			wantRelief:   []int{2},
+			wantOwner:    myID,
+		},
+		{
+			name:         "we are relieved when we are unhealthy",
+			mine:         unhealthyCar(2, stuck),
+			others:       map[int]elevator.ElevatorState{2: healthyCar(0)},
+			wantAssigned: true,
+			wantRelieved: true,
+			wantOwner:    2,
+		},
+		{
+			name:         "every car is relieved when none is healthy, although nothing can be assigned",
+			mine:         unhealthyCar(2, stuck),
+			others:       map[int]elevator.ElevatorState{2: unhealthyCar(1, elevator.ElevatorHealth{MotorFault: true})},
+			wantRelieved: true,
+			wantRelief:   []int{2},
+			wantOwner:    -1,
+		},
+	}
+	for _, test := range tests {
+		states := map[int]elevator.ElevatorState{}
+		for id, state := range test.others {
+			states[id] = state
+		}
+		result, _ := ComputeHallAssignments(assigner.NearestCarAssigner{}, true,
+			messagehandler.ElevStateUpdate{NodeElevStatesMap: states, OnlyActiveNodes: true},
+			messages.NodeElevState{NodeID: myID, ElevState: test.mine},
+			hallRequests, nil, nil)
+
+		if result.Assigned != test.wantAssigned || result.Relieved != test.wantRelieved {
+			t.Errorf("%s: assigned %v, relieved %v, want %v and %v", test.name, result.Assigned, result.Relieved, test.wantAssigned, test.wantRelieved)
+		}
+		if test.wantRelieved && (result.MyAssignment.HallAssignments != [config.NUM_FLOORS][2]bool{} || result.MyAssignment.HomeFloor != -1) {
+			t.Errorf("%s: relieved with %v", test.name, result.MyAssignment)
+		}
+		if result.MyAssignment.LightStates != hallRequests {
+			t.Errorf("%s: got lights %v, want %v", test.name, result.MyAssignment.LightStates, hallRequests)
+		}
+		for _, id := range test.wantRelief {
+			relief, ok := result.OtherAssignments[id]
+			if !ok || relief.HallAssignment != [config.NUM_FLOORS][2]bool{} || relief.HomeFloor != -1 {
+				t.Errorf("%s: car %d got %v, %v, want an empty assignment", test.name, id, relief, ok)
+			}
+		}
+
+		owner := -1
+		if result.MyAssignment.HallAssignments[2][0] {
+			owner = myID
+		}
+		for id, assignment := range result.OtherAssignments {
+			if assignment.HallAssignment[2][0] {
+				owner = id
+			}
+		}
+		if owner != test.wantOwner {
+			t.Errorf("%s: hall request given to %d, want %d", test.name, owner, test.wantOwner)
+		}
+	}
+}
@@ -8,8 +8,10 @@ import (
 	"elev/Network/messages"
 	"elev/Network/network/bcast"
 	"elev/config"
+	"elev/costFNS/assigner"
 	"elev/elevator"
 	"elev/singleelevator"
+	"fmt"
 	"time"
 )
 
@@ -24,6 +26,7 @@ const (
 	Disconnected
 	Master
 	Slave
+	Maintenance
 )
 
 type NodeData struct {
@@ -31,6 +34,24 @@ type NodeData struct {
 	State              nodestate
 	GlobalHallRequests [config.NUM_FLOORS][2]bool
 	TOLC               time.Time
+	HallAssigner       assigner.Assigner             // distributes the hall requests when the node is master
+	AssignmentAudit    *assigner.AuditLog            // explains the recent hall assignments made by this node
+	HallEscalator      *assigner.EscalatingAssigner  // forces the hall requests that have waited too long to the nearest car
+	HallDestinations   *assigner.DestinationAssigner // gives the hall requests of the destination calls to their cars
+	HallGuard          *assigner.GuardedAssigner     // replaces a failing hall assigner with the nearest car, and raises the alarm
+	Authenticator      *bcast.Authenticator          // signs and checks our packets, nil if authentication is off
+	faults             elevatorFaults                // the faults last reported by our elevator
+
+	OperatorCommandRx    chan OperatorCommand // receives commands from the operator console
+	MaintenanceParkFloor int                  // the floor to park at in maintenance
+	IndependentService   bool                 // the car is dedicated to a single user and takes no hall assignments
+
+	RecallActive      bool                   // a fire recall is in effect on this node
+	RecallFloor       int                    // the floor the fire recall sends the car to
+	lastRecallCommand messages.RecallCommand // the last recall command received from a master
+
+	DestinationCalls []messages.DestinationCall // destination calls waiting to be picked up, with their cars
+	announcedCalls   map[uint64]int             // the car each passenger at our panel has been told to take, by call id
 
 	AckTx               chan messages.Ack                   // Send acks to udp broadcaster
 	NodeElevStatesTx    chan messages.NodeElevState         // send your elev states to udp broadcaster
@@ -54,6 +75,14 @@ type NodeData struct {
 	NewHallReqTx chan messages.NewHallRequest // Sends new hall requests to other nodes
 	NewHallReqRx chan messages.NewHallRequest // Receives new hall requests from other nodes
 
+	RecallCommandTx chan messages.RecallCommand // update the recall command transmitter with the recall state of the master
+	RecallCommandRx chan messages.RecallCommand // receive recall commands from udp receiver
+
+	NewDestinationReqTx      chan messages.NewDestinationRequest  // Sends destination calls entered at our panel to master
+	NewDestinationReqRx      chan messages.NewDestinationRequest  // Receives destination calls from other nodes
+	DestinationAssignmentsTx chan messages.DestinationAssignments // update the destination assignments transmitter with the destination calls of the master
+	DestinationAssignmentsRx chan messages.DestinationAssignments // receive the destination calls from udp receiver
+
 	// Elevator-Node communication
 	ElevLightAndAssignmentUpdateTx chan singleelevator.LightAndAssignmentUpdate // channel for informing elevator of changes to hall button lights, hall assignments and cab assignments
 	ElevatorEventRx                chan singleelevator.ElevatorEvent
@@ -66,16 +95,23 @@ type NodeData struct {
 	GlobalHallReqTransmitEnableTx          chan bool // channel that connects to GlobalHallRequestTransmitter, should be enabled when node is master
 	HallRequestAssignerTransmitEnableTx    chan bool // channel that connects to HallAssignmentsTransmitter, should be enabled when node is master
 	HallAssignmentCompleteTransmitEnableTx chan bool // channel that connects to HallAssignmentCompleteTransmitter, should be enabled when node is master
+	RecallCommandTransmitEnableTx          chan bool // channel that connects to RecallCommandTransmitter, should be enabled when node is master
+	DestinationAssignmentsTransmitEnableTx chan bool // channel that connects to DestinationAssignmentsTransmitter, should be enabled when node is master
 }
 
 // initialize a network node and return a nodedata obj, needed for communication with the processes it starts
-func MakeNode(id int, portNum string, bcastBroadcasterPort int, bcastReceiverPort int) *NodeData {
+func MakeNode(id int, drv elevator.Driver, bcastBroadcasterPort int, bcastReceiverPort int) *NodeData {
 
 	node := &NodeData{
 		ID:    id,
 		State: Inactive,
 		TOLC:  time.Time{},
+		// the elevator only reports changes of the connection to the server, so we start from the driver
+		faults: elevatorFaults{serverIsConnected: drv.IsConnected()},
 	}
+	node.announcedCalls = make(map[uint64]int)
+
+	node.OperatorCommandRx = make(chan OperatorCommand)
 
 	node.AckTx = make(chan messages.Ack)
 	ackRx := make(chan messages.Ack)
@@ -95,6 +131,16 @@ func MakeNode(id int, portNum string, bcastBroadcasterPort int, bcastReceiverPor
 	node.HallAssignmentCompleteTx = make(chan messages.HallAssignmentComplete)
 	node.HallAssignmentCompleteRx = make(chan messages.HallAssignmentComplete)
 
+	node.RecallCommandTx = make(chan messages.RecallCommand)
+	node.RecallCommandRx = make(chan messages.RecallCommand)
+	recallCommandTransToBroadcast := make(chan messages.RecallCommand)
+
+	node.NewDestinationReqTx = make(chan messages.NewDestinationRequest)
+	node.NewDestinationReqRx = make(chan messages.NewDestinationRequest)
+	node.DestinationAssignmentsTx = make(chan messages.DestinationAssignments)
+	node.DestinationAssignmentsRx = make(chan messages.DestinationAssignments)
+	destinationAssignmentsTransToBroadcast := make(chan messages.DestinationAssignments)
+
 	HATransToBcastTx := make(chan messages.NewHallAssignments) // channel for communication from Hall Assignment Transmitter process to Broadcaster
 	globalHallReqTransToBroadcast := make(chan messages.GlobalHallRequest)
 	HACompleteTransToBcast := make(chan messages.HallAssignmentComplete)
@@ -103,6 +149,8 @@ func MakeNode(id int, portNum string, bcastBroadcasterPort int, bcastReceiverPor
 	node.GlobalHallReqTransmitEnableTx = make(chan bool)
 	node.HallRequestAssignerTransmitEnableTx = make(chan bool)
 	node.HallAssignmentCompleteTransmitEnableTx = make(chan bool)
+	node.RecallCommandTransmitEnableTx = make(chan bool)
+	node.DestinationAssignmentsTransmitEnableTx = make(chan bool)
 
 	node.HallAssignmentTx = make(chan messages.NewHallAssignments)
 	node.HallAssignmentsRx = make(chan messages.NewHallAssignments)
@@ -125,8 +173,22 @@ func MakeNode(id int, portNum string, bcastBroadcasterPort int, bcastReceiverPor
 	node.GlobalHallReqTransmitEnableTx = make(chan bool)
 	receiverToServerCh := make(chan messages.NodeElevState)
 
+	wireFormat, err := bcast.WireFormatFromName(config.WIRE_FORMAT)
+	if err != nil {
+		fmt.Printf("Error: %v, falling back to JSON\n", err)
+	}
+	if config.CLUSTER_KEY_FILE != "" {
+		node.Authenticator, err = bcast.LoadAuthenticator(config.CLUSTER_KEY_FILE, config.AUTH_REPLAY_WINDOW)
+		if err != nil {
+			// running without authentication would let anyone on the network take over the elevators
+			panic(fmt.Sprintf("Could not load the cluster key: %v", err))
+		}
+	}
+	bcastOptions := bcast.Options{Format: wireFormat, Auth: node.Authenticator}
+
 	// start process that broadcast all messages on these channels to udp
 	go bcast.Broadcaster(bcastBroadcasterPort,
+		bcastOptions,
 		node.AckTx,
 		node.NodeElevStatesTx,
 		HACompleteTransToBcast,
@@ -134,10 +196,14 @@ func MakeNode(id int, portNum string, bcastBroadcasterPort int, bcastReceiverPor
 		node.CabRequestInfoTx,
 		globalHallReqTransToBroadcast,
 		node.ConnectionReqTx,
-		node.NewHallReqTx)
+		node.NewHallReqTx,
+		recallCommandTransToBroadcast,
+		node.NewDestinationReqTx,
+		destinationAssignmentsTransToBroadcast)
 
 	// start receiver process that listens for messages on the port
 	go bcast.Receiver(bcastReceiverPort,
+		bcastOptions,
 		ackRx,
 		receiverToServerCh,
 		node.HallAssignmentsRx,
@@ -145,7 +211,10 @@ func MakeNode(id int, portNum string, bcastBroadcasterPort int, bcastReceiverPor
 		node.CabRequestInfoRx,
 		node.GlobalHallRequestRx,
 		node.ConnectionReqRx,
-		node.HallAssignmentCompleteRx)
+		node.HallAssignmentCompleteRx,
+		node.RecallCommandRx,
+		node.NewDestinationReqRx,
+		node.DestinationAssignmentsRx)
 
 	// process for distributing incoming acks in ackRx to different processes
 	go messagehandler.IncomingAckDistributor(ackRx,
@@ -165,8 +234,28 @@ func MakeNode(id int, portNum string, bcastBroadcasterPort int, bcastReceiverPor
 		hallAssignmentCompleteAckRx,
 		node.HallAssignmentCompleteTransmitEnableTx)
 
+	hallAssigner, err := assigner.AssignerFromName(config.HALL_ASSIGNER)
+	if err != nil {
+		fmt.Printf("Error: %v, falling back to the hall request assigner\n", err)
+		hallAssigner = assigner.HRAAssigner{}
+	}
+	node.AssignmentAudit = assigner.NewAuditLog(config.ASSIGNMENT_AUDIT_SIZE)
+	// a failing hall assigner is replaced by the nearest car assigner until it recovers
+	node.HallDestinations = assigner.NewDestinationAssigner(hallAssigner)
+	node.HallGuard = assigner.NewGuardedAssigner(node.HallDestinations, assigner.NearestCarAssigner{}, config.ASSIGNMENT_TIMEOUT)
+	node.HallEscalator = assigner.NewEscalatingAssigner(
+		assigner.NewStableAssigner(node.HallGuard, config.ASSIGNMENT_REASSIGN_THRESHOLD))
+	node.HallAssigner = assigner.NewAuditingAssigner(node.HallEscalator, node.AssignmentAudit)
+
+	strategy, err := elevator.StrategyFromName(config.REQUEST_STRATEGY)
+	if err != nil {
+		fmt.Printf("Error: %v, falling back to collective control\n", err)
+		strategy = elevator.CollectiveStrategy{}
+	}
+
 	// the physical elevator program
-	go singleelevator.ElevatorProgram(portNum,
+	go singleelevator.ElevatorProgram(drv,
+		strategy,
 		node.ElevatorEventRx,
 		node.ElevLightAndAssignmentUpdateTx,
 		node.MyElevStatesRx)
@@ -183,5 +272,13 @@ func MakeNode(id int, portNum string, bcastBroadcasterPort int, bcastReceiverPor
 		globalHallReqTransToBroadcast,
 		node.GlobalHallRequestTx)
 
+	go messagehandler.RecallCommandTransmitter(node.RecallCommandTransmitEnableTx,
+		recallCommandTransToBroadcast,
+		node.RecallCommandTx)
+
+	go messagehandler.DestinationAssignmentsTransmitter(node.DestinationAssignmentsTransmitEnableTx,
+		destinationAssignmentsTransToBroadcast,
+		node.DestinationAssignmentsTx)
+
 	return node
 }
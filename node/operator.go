@@ -0,0 +1,219 @@
+package node
+
+import (
+	"bufio"
+	"elev/Network/messages"
+	"elev/config"
+	"elev/costFNS/assigner"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type OperatorCommandType int
+
+const defaultAuditCount = 10 // number of decisions printed by a plain "audit"
+
+const (
+	MaintenanceOnCommand   OperatorCommandType = iota // take the car out of service, Floor is the park floor
+	MaintenanceOffCommand                             // put the car back in service
+	RecallOnCommand                                   // start a fire recall, Floor is the recall floor
+	RecallOffCommand                                  // reset the fire recall
+	IndependentOnCommand                              // dedicate the car to a single user, it only takes cab calls
+	IndependentOffCommand                             // put the car back in normal service
+	AuditShowCommand                                  // print the last Count assignment decisions, for Floor only if it is not -1
+	AuditDumpCommand                                  // write the assignment decisions to the file at Path
+	DestinationCallCommand                            // a passenger at Floor enters Destination at the panel of this node
+	AuthStatsCommand                                  // print the number of packets accepted and rejected by authentication
+	AssignerStatusCommand                             // print the alarm state of the hall assigner
+)
+
+// OperatorCommand is a command given to the node at runtime by an operator
+type OperatorCommand struct {
+	Type        OperatorCommandType
+	Floor       int
+	Count       int
+	Path        string
+	Destination int
+}
+
+// ParseOperatorCommand parses a single console line, for example "maintenance on 2", "maintenance off", "recall on", "independent on",
+// "audit", "audit 20", "audit floor 2", "audit dump audit.jsonl", "call 0 3", "auth" or "assigner"
+func ParseOperatorCommand(line string) (OperatorCommand, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 1 && fields[0] == "audit" {
+		return OperatorCommand{Type: AuditShowCommand, Floor: -1, Count: defaultAuditCount}, nil
+	}
+	if len(fields) == 1 && fields[0] == "auth" {
+		return OperatorCommand{Type: AuthStatsCommand}, nil
+	}
+	if len(fields) == 1 && fields[0] == "assigner" {
+		return OperatorCommand{Type: AssignerStatusCommand}, nil
+	}
+	if len(fields) < 2 {
+		return OperatorCommand{}, fmt.Errorf("unknown command %q", line)
+	}
+
+	switch fields[0] {
+	case "maintenance":
+		switch fields[1] {
+		case "on":
+			floor, err := parseOptionalFloor(fields[2:], config.MAINTENANCE_PARK_FLOOR)
+			return OperatorCommand{Type: MaintenanceOnCommand, Floor: floor}, err
+		case "off":
+			return OperatorCommand{Type: MaintenanceOffCommand}, nil
+		}
+	case "recall":
+		switch fields[1] {
+		case "on":
+			floor, err := parseOptionalFloor(fields[2:], config.RECALL_FLOOR)
+			return OperatorCommand{Type: RecallOnCommand, Floor: floor}, err
+		case "off":
+			return OperatorCommand{Type: RecallOffCommand}, nil
+		}
+	case "audit":
+		switch fields[1] {
+		case "floor":
+			floor, err := parseOptionalFloor(fields[2:], -1)
+			return OperatorCommand{Type: AuditShowCommand, Floor: floor, Count: config.ASSIGNMENT_AUDIT_SIZE}, err
+		case "dump":
+			if len(fields) < 3 {
+				return OperatorCommand{}, fmt.Errorf("missing file in %q", line)
+			}
+			return OperatorCommand{Type: AuditDumpCommand, Path: fields[2]}, nil
+		default:
+			count, err := strconv.Atoi(fields[1])
+			if err != nil || count <= 0 {
+				return OperatorCommand{}, fmt.Errorf("invalid count %q", fields[1])
+			}
+			return OperatorCommand{Type: AuditShowCommand, Floor: -1, Count: count}, nil
+		}
+	case "call":
+		if len(fields) < 3 {
+			return OperatorCommand{}, fmt.Errorf("missing destination in %q", line)
+		}
+		origin, err := parseFloor(fields[1])
+		if err != nil {
+			return OperatorCommand{}, err
+		}
+		destination, err := parseFloor(fields[2])
+		if err == nil && destination == origin {
+			err = fmt.Errorf("destination is the same as the origin in %q", line)
+		}
+		return OperatorCommand{Type: DestinationCallCommand, Floor: origin, Destination: destination}, err
+	case "independent":
+		switch fields[1] {
+		case "on":
+			return OperatorCommand{Type: IndependentOnCommand}, nil
+		case "off":
+			return OperatorCommand{Type: IndependentOffCommand}, nil
+		}
+	}
+	return OperatorCommand{}, fmt.Errorf("unknown command %q", line)
+}
+
+// OperatorConsole reads operator commands line by line and sends them to the node
+func OperatorConsole(r io.Reader, commandTx chan<- OperatorCommand) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		command, err := ParseOperatorCommand(line)
+		if err != nil {
+			fmt.Printf("Operator console: %v\n", err)
+			continue
+		}
+		commandTx <- command
+	}
+}
+
+// handleOperatorCommand carries out the operator commands that do not change the node state.
+// Returns true if the node should go to Maintenance.
+func (node *NodeData) handleOperatorCommand(command OperatorCommand) bool {
+	switch command.Type {
+	case MaintenanceOnCommand:
+		node.MaintenanceParkFloor = command.Floor
+		return true
+
+	case RecallOnCommand, RecallOffCommand:
+		node.setRecall(command.Type == RecallOnCommand, command.Floor)
+		if node.State == Master {
+			// recall the whole group, the recall command transmitter brings it to the other nodes
+			node.RecallCommandTx <- messages.RecallCommand{Active: node.RecallActive, Floor: node.RecallFloor}
+			node.GlobalHallRequestTx <- messages.GlobalHallRequest{HallRequests: node.GlobalHallRequests}
+			node.DestinationAssignmentsTx <- messages.DestinationAssignments{Calls: node.DestinationCalls}
+		}
+
+	case IndependentOnCommand, IndependentOffCommand:
+		node.IndependentService = command.Type == IndependentOnCommand
+		fmt.Printf("Node %d: independent service %t\n", node.ID, node.IndependentService)
+		// in maintenance the mode is applied when the car is put back in service
+		if node.State != Maintenance {
+			node.ElevLightAndAssignmentUpdateTx <- node.makeServiceModeMessage(node.inServiceMode(), -1)
+		}
+
+	case DestinationCallCommand:
+		// master registers its own calls, see MasterProgram
+		switch {
+		case !config.DESTINATION_DISPATCH:
+			fmt.Println("Destination dispatch is disabled")
+		case node.State == Slave || node.State == Maintenance:
+			req, err := node.makeDestinationRequest(command.Floor, command.Destination)
+			if err != nil {
+				fmt.Printf("Fatal error: %v\n", err)
+				break
+			}
+			node.NewDestinationReqTx <- req
+		default:
+			fmt.Println("No master to take the destination call, try again later")
+		}
+
+	case AuditShowCommand:
+		records := node.AssignmentAudit.Records(func(record assigner.AuditRecord) bool {
+			return command.Floor == -1 || record.Floor == command.Floor
+		})
+		if len(records) > command.Count {
+			records = records[len(records)-command.Count:]
+		}
+		fmt.Printf("Node %d: %d hall assignment decisions\n", node.ID, len(records))
+		for _, record := range records {
+			fmt.Println(record)
+		}
+
+	case AuditDumpCommand:
+		if err := node.AssignmentAudit.Dump(command.Path); err != nil {
+			fmt.Printf("Node %d: could not dump the hall assignment decisions: %v\n", node.ID, err)
+		}
+
+	case AuthStatsCommand:
+		if node.Authenticator == nil {
+			fmt.Println("Authentication is off, set CLUSTER_KEY_FILE to turn it on")
+			break
+		}
+		fmt.Printf("Node %d: packets %v\n", node.ID, node.Authenticator.Stats())
+
+	case AssignerStatusCommand:
+		// the hall assigner only runs on the master, so the alarm of a slave tells of its last time as master
+		fmt.Printf("Node %d: hall assigner %v\n", node.ID, node.HallGuard.Status())
+	}
+	return false
+}
+
+// parseOptionalFloor parses the floor argument of a command, or returns defaultFloor if it is left out
+func parseOptionalFloor(args []string, defaultFloor int) (int, error) {
+	if len(args) == 0 {
+		return defaultFloor, nil
+	}
+	return parseFloor(args[0])
+}
+
+func parseFloor(s string) (int, error) {
+	floor, err := strconv.Atoi(s)
+	if err != nil || floor < 0 || floor >= config.NUM_FLOORS {
+		return 0, fmt.Errorf("invalid floor %q", s)
+	}
+	return floor, nil
+}
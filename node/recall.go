@@ -0,0 +1,65 @@
+package node
+
+import (
+	"elev/Network/messages"
+	"elev/config"
+	"elev/elevator"
+	"elev/singleelevator"
+	"fmt"
+)
+
+// setRecall engages or resets the fire recall on this node. Engaging cancels all hall requests and sends the car
+// nonstop to the recall floor. Resetting puts the car back in the service mode of the current node state.
+func (node *NodeData) setRecall(active bool, floor int) {
+	if active == node.RecallActive && (!active || floor == node.RecallFloor) {
+		return
+	}
+	node.RecallActive = active
+	node.RecallFloor = floor
+
+	if active {
+		fmt.Printf("Node %d: fire recall to floor %d\n", node.ID, floor)
+		node.GlobalHallRequests = [config.NUM_FLOORS][2]bool{}
+		node.DestinationCalls = nil
+		node.ElevLightAndAssignmentUpdateTx <- makeLightMessage(node.GlobalHallRequests)
+	} else {
+		fmt.Printf("Node %d: fire recall reset\n", node.ID)
+	}
+
+	if node.State == Maintenance {
+		node.ElevLightAndAssignmentUpdateTx <- node.makeServiceModeMessage(elevator.MaintenanceService, node.MaintenanceParkFloor)
+	} else {
+		node.ElevLightAndAssignmentUpdateTx <- node.makeServiceModeMessage(node.inServiceMode(), -1)
+	}
+}
+
+// handleRecallCommand follows the recall command of a master. Only changes are acted on, so a recall
+// triggered locally is not reset by a master that has never been recalled.
+func (node *NodeData) handleRecallCommand(command messages.RecallCommand) {
+	if command == node.lastRecallCommand {
+		return
+	}
+	node.lastRecallCommand = command
+	node.setRecall(command.Active, command.Floor)
+}
+
+// inServiceMode is the service mode of the car when it is not in maintenance
+func (node *NodeData) inServiceMode() elevator.ServiceMode {
+	if node.IndependentService {
+		return elevator.IndependentService
+	}
+	return elevator.NormalService
+}
+
+// makeServiceModeMessage makes the service mode order for the elevator. An active fire recall overrides the requested mode.
+func (node *NodeData) makeServiceModeMessage(mode elevator.ServiceMode, parkFloor int) singleelevator.LightAndAssignmentUpdate {
+	if node.RecallActive {
+		mode = elevator.RecallService
+		parkFloor = node.RecallFloor
+	}
+	return singleelevator.LightAndAssignmentUpdate{
+		OrderType:   singleelevator.ServiceModeOrder,
+		ServiceMode: mode,
+		ParkFloor:   parkFloor,
+	}
+}
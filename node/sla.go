@@ -0,0 +1,40 @@
+package node
+
+import (
+	"elev/config"
+	"elev/elevator"
+	"fmt"
+	"time"
+)
+
+// hallRequestWaits tracks how long the hall requests have been outstanding on master.
+// A new master starts the clock of the requests it takes over when it takes them over.
+type hallRequestWaits struct {
+	since     [config.NUM_FLOORS][2]time.Time // when each outstanding request was first seen
+	escalated [config.NUM_FLOORS][2]bool      // the requests that have waited longer than HALL_REQUEST_SLA
+}
+
+// update timestamps the new hall requests and forgets the served ones. Requests that have waited longer than
+// HALL_REQUEST_SLA are escalated and logged as breaches. Returns true if a request was escalated.
+func (waits *hallRequestWaits) update(hallRequests [config.NUM_FLOORS][2]bool, now time.Time) bool {
+	escalated := false
+	for floor := 0; floor < config.NUM_FLOORS; floor++ {
+		for btn := 0; btn < 2; btn++ {
+			switch {
+			case !hallRequests[floor][btn]:
+				waits.since[floor][btn] = time.Time{}
+				waits.escalated[floor][btn] = false
+
+			case waits.since[floor][btn].IsZero():
+				waits.since[floor][btn] = now
+
+			case !waits.escalated[floor][btn] && now.Sub(waits.since[floor][btn]) > config.HALL_REQUEST_SLA:
+				waits.escalated[floor][btn] = true
+				escalated = true
+				fmt.Printf("SLA breach: hall request at floor %d %s has waited %v\n",
+					floor, elevator.ButtonType(btn), now.Sub(waits.since[floor][btn]).Round(time.Second))
+			}
+		}
+	}
+	return escalated
+}
@@ -31,6 +31,7 @@ ForLoop:
 	Select:
 		select {
 		case elevMsg := <-node.ElevatorEventRx:
+			node.faults.update(elevMsg)
 
 			switch elevMsg.EventType {
 			case singleelevator.DoorStuckEvent:
@@ -39,6 +40,27 @@ ForLoop:
 					break ForLoop
 				}
 
+			case singleelevator.MotorFaultEvent:
+				// the car cannot reach its hall assignments, go inactive so they are reassigned
+				if elevMsg.HasMotorFault {
+					nextNodeState = Inactive
+					break ForLoop
+				}
+
+			case singleelevator.EmergencyStopEvent:
+				// withdraw from hall assignment until the emergency stop is released
+				if elevMsg.IsStopped {
+					nextNodeState = Inactive
+					break ForLoop
+				}
+
+			case singleelevator.ServerConnectionEvent:
+				// without the elevator server we cannot serve our hall assignments, go inactive so they are handed off
+				if !elevMsg.ServerIsConnected {
+					nextNodeState = Inactive
+					break ForLoop
+				}
+
 			case singleelevator.HallButtonEvent:
 				node.NewHallReqTx <- messages.NewHallRequest{
 					Floor:      elevMsg.ButtonEvent.Floor,
@@ -65,6 +87,7 @@ ForLoop:
 			node.NodeElevStatesTx <- messages.NodeElevState{
 				NodeID:    node.ID,
 				ElevState: myElevStates,
+				Protocol:  messages.OwnProtocol(),
 			}
 
 		case networkEvent := <-node.NetworkEventRx:
@@ -84,7 +107,7 @@ ForLoop:
 
 			// lets check if I have already received this message, if not its update time!
 			if lastHallAssignmentMessageID != newHA.MessageID {
-				node.ElevLightAndAssignmentUpdateTx <- makeHallAssignmentAndLightMessage(newHA.HallAssignment, node.GlobalHallRequests)
+				node.ElevLightAndAssignmentUpdateTx <- makeHallAssignmentAndLightMessage(newHA.HallAssignment, node.GlobalHallRequests, newHA.HomeFloor, newHA.Destinations)
 				lastHallAssignmentMessageID = newHA.MessageID
 			}
 
@@ -100,8 +123,22 @@ ForLoop:
 			nextNodeState = Disconnected
 			break ForLoop
 
+		case command := <-node.OperatorCommandRx:
+			if node.handleOperatorCommand(command) {
+				nextNodeState = Maintenance
+				break ForLoop
+			}
+
+		case recallCommand := <-node.RecallCommandRx:
+			node.handleRecallCommand(recallCommand)
+
+		case destinationAssignments := <-node.DestinationAssignmentsRx:
+			node.DestinationCalls = destinationAssignments.Calls
+			node.announceDestinationCalls()
+
 		case <-node.NodeElevStateUpdate:
 		case <-node.NewHallReqRx:
+		case <-node.NewDestinationReqRx:
 		case <-node.ConnectionReqRx:
 		case <-node.CabRequestInfoRx:
 		case <-node.HallAssignmentCompleteRx:
@@ -111,9 +148,12 @@ ForLoop:
 
 	// stop transmitters
 	node.HallAssignmentCompleteTransmitEnableTx <- false
-	if nextNodeState == Disconnected {
+	switch nextNodeState {
+	case Disconnected:
 		fmt.Println("Exiting slave to disconnected")
-	} else {
+	case Maintenance:
+		fmt.Println("Exiting slave to maintenance")
+	default:
 		fmt.Println("Exiting slave to inactive")
 	}
 
@@ -133,9 +173,12 @@ func canAcceptHallAssignments(newHallAssignments, globalHallReq [config.NUM_FLOO
 	return true
 }
 
-func makeHallAssignmentAndLightMessage(hallAssignments [config.NUM_FLOORS][2]bool, globalHallReq [config.NUM_FLOORS][2]bool) singleelevator.LightAndAssignmentUpdate {
+func makeHallAssignmentAndLightMessage(hallAssignments [config.NUM_FLOORS][2]bool, globalHallReq [config.NUM_FLOORS][2]bool, homeFloor int,
+	destinations [config.NUM_FLOORS][2][config.NUM_FLOORS]bool) singleelevator.LightAndAssignmentUpdate {
 	var newMessage singleelevator.LightAndAssignmentUpdate
 	newMessage.HallAssignments = hallAssignments
+	newMessage.HomeFloor = homeFloor
+	newMessage.Destinations = destinations
 	newMessage.LightStates = globalHallReq
 	newMessage.OrderType = singleelevator.HallOrder
 	return newMessage
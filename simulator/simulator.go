@@ -0,0 +1,308 @@
+// Package simulator implements the server side of the elevator hardware protocol used by the
+// elevator package, so that nodes can be run and tested without the external course simulator.
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+const NUM_BUTTON_TYPES = 3
+
+// Button types, matching the button encoding of the elevio protocol
+const (
+	ButtonHallUp   = 0
+	ButtonHallDown = 1
+	ButtonCab      = 2
+)
+
+// Commands of the 4-byte elevio protocol
+const (
+	cmdReload         byte = 0
+	cmdMotorDirection byte = 1
+	cmdButtonLamp     byte = 2
+	cmdFloorIndicator byte = 3
+	cmdDoorOpenLamp   byte = 4
+	cmdStopLamp       byte = 5
+	cmdButtonPressed  byte = 6
+	cmdFloorSensor    byte = 7
+	cmdStopButton     byte = 8
+	cmdObstruction    byte = 9
+)
+
+const stepInterval = 10 * time.Millisecond
+const floorSensorFraction = 0.1 // fraction of a floor around each floor where the floor sensor is active
+
+type Config struct {
+	NumFloors           int
+	TravelDuration      time.Duration // time it takes to travel from one floor to the next
+	ButtonPressDuration time.Duration // how long an injected button press is held down
+	StartFloor          int           // the floor the car starts at, -1 places it between the two lowest floors
+}
+
+func DefaultConfig() Config {
+	return Config{
+		NumFloors:           4,
+		TravelDuration:      2 * time.Second,
+		ButtonPressDuration: 200 * time.Millisecond,
+		StartFloor:          -1,
+	}
+}
+
+// State is a snapshot of the simulated hardware
+type State struct {
+	Position       float64 // position of the car measured in floors
+	MotorDirection int
+	FloorSensor    int // -1 when the car is between floors
+	FloorIndicator int
+	DoorOpenLamp   bool
+	StopLamp       bool
+	StopPressed    bool
+	Obstructed     bool
+	ButtonLamps    [][NUM_BUTTON_TYPES]bool
+	ButtonsPressed [][NUM_BUTTON_TYPES]bool
+}
+
+// Simulator holds the physical model of a single elevator car and its panel
+type Simulator struct {
+	mu  sync.Mutex
+	cfg Config
+
+	position       float64
+	motorDirection int
+	floorIndicator int
+	doorOpenLamp   bool
+	stopLamp       bool
+	stopReleaseAt  time.Time
+	obstructed     bool
+
+	buttonLamps     [][NUM_BUTTON_TYPES]bool
+	buttonReleaseAt [][NUM_BUTTON_TYPES]time.Time
+}
+
+func New(cfg Config) *Simulator {
+	if cfg.NumFloors < 2 {
+		cfg.NumFloors = 2
+	}
+	sim := &Simulator{
+		cfg:             cfg,
+		buttonLamps:     make([][NUM_BUTTON_TYPES]bool, cfg.NumFloors),
+		buttonReleaseAt: make([][NUM_BUTTON_TYPES]time.Time, cfg.NumFloors),
+	}
+	if cfg.StartFloor >= 0 && cfg.StartFloor < cfg.NumFloors {
+		sim.position = float64(cfg.StartFloor)
+	} else {
+		sim.position = 0.5
+	}
+	return sim
+}
+
+// Run advances the physical model in real time. It never returns.
+func (sim *Simulator) Run() {
+	last := time.Now()
+	for now := range time.Tick(stepInterval) {
+		sim.Step(now.Sub(last))
+		last = now
+	}
+}
+
+// Step moves the car according to the motor direction for the duration dt
+func (sim *Simulator) Step(dt time.Duration) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	if sim.motorDirection == 0 || sim.cfg.TravelDuration <= 0 {
+		return
+	}
+	previous := sim.position
+	sim.position += float64(sim.motorDirection) * float64(dt) / float64(sim.cfg.TravelDuration)
+
+	top := float64(sim.cfg.NumFloors - 1)
+	if sim.position < 0 {
+		if previous > 0 {
+			fmt.Println("Simulator: the car hit the bottom of the shaft")
+		}
+		sim.position = 0
+	} else if sim.position > top {
+		if previous < top {
+			fmt.Println("Simulator: the car hit the top of the shaft")
+		}
+		sim.position = top
+	}
+}
+
+// PressButton holds the given button down for the configured press duration
+func (sim *Simulator) PressButton(button int, floor int) error {
+	if button < 0 || button >= NUM_BUTTON_TYPES || floor < 0 || floor >= sim.cfg.NumFloors {
+		return fmt.Errorf("invalid button %d at floor %d", button, floor)
+	}
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.buttonReleaseAt[floor][button] = time.Now().Add(sim.cfg.ButtonPressDuration)
+	return nil
+}
+
+// PressStop holds the stop button down for the configured press duration
+func (sim *Simulator) PressStop() {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.stopReleaseAt = time.Now().Add(sim.cfg.ButtonPressDuration)
+}
+
+func (sim *Simulator) SetObstruction(isObstructed bool) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.obstructed = isObstructed
+}
+
+func (sim *Simulator) ToggleObstruction() {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.obstructed = !sim.obstructed
+}
+
+// Snapshot returns a copy of the current hardware state
+func (sim *Simulator) Snapshot() State {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	now := time.Now()
+	state := State{
+		Position:       sim.position,
+		MotorDirection: sim.motorDirection,
+		FloorSensor:    sim.floorSensor(),
+		FloorIndicator: sim.floorIndicator,
+		DoorOpenLamp:   sim.doorOpenLamp,
+		StopLamp:       sim.stopLamp,
+		StopPressed:    now.Before(sim.stopReleaseAt),
+		Obstructed:     sim.obstructed,
+		ButtonLamps:    make([][NUM_BUTTON_TYPES]bool, sim.cfg.NumFloors),
+		ButtonsPressed: make([][NUM_BUTTON_TYPES]bool, sim.cfg.NumFloors),
+	}
+	copy(state.ButtonLamps, sim.buttonLamps)
+	for floor := range sim.buttonReleaseAt {
+		for button := range sim.buttonReleaseAt[floor] {
+			state.ButtonsPressed[floor][button] = now.Before(sim.buttonReleaseAt[floor][button])
+		}
+	}
+	return state
+}
+
+// HandleCommand executes a single protocol command. The returned bool is true if the command expects a reply.
+func (sim *Simulator) HandleCommand(cmd [4]byte) ([4]byte, bool) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	switch cmd[0] {
+	case cmdReload:
+	case cmdMotorDirection:
+		sim.motorDirection = int(int8(cmd[1]))
+
+	case cmdButtonLamp:
+		if sim.validButton(int(cmd[1]), int(cmd[2])) {
+			sim.buttonLamps[cmd[2]][cmd[1]] = cmd[3] != 0
+		}
+
+	case cmdFloorIndicator:
+		if int(cmd[1]) < sim.cfg.NumFloors {
+			sim.floorIndicator = int(cmd[1])
+		}
+
+	case cmdDoorOpenLamp:
+		sim.doorOpenLamp = cmd[1] != 0
+
+	case cmdStopLamp:
+		sim.stopLamp = cmd[1] != 0
+
+	case cmdButtonPressed:
+		pressed := false
+		if sim.validButton(int(cmd[1]), int(cmd[2])) {
+			pressed = time.Now().Before(sim.buttonReleaseAt[cmd[2]][cmd[1]])
+		}
+		return [4]byte{cmdButtonPressed, toByte(pressed), 0, 0}, true
+
+	case cmdFloorSensor:
+		floor := sim.floorSensor()
+		if floor == -1 {
+			return [4]byte{cmdFloorSensor, 0, 0, 0}, true
+		}
+		return [4]byte{cmdFloorSensor, 1, byte(floor), 0}, true
+
+	case cmdStopButton:
+		return [4]byte{cmdStopButton, toByte(time.Now().Before(sim.stopReleaseAt)), 0, 0}, true
+
+	case cmdObstruction:
+		return [4]byte{cmdObstruction, toByte(sim.obstructed), 0, 0}, true
+
+	default:
+		fmt.Printf("Simulator: unknown command %v\n", cmd)
+	}
+	return [4]byte{}, false
+}
+
+// ListenAndServe accepts elevio clients on addr and serves them until the listener fails
+func (sim *Simulator) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	fmt.Printf("Simulator: listening on %s\n", listener.Addr())
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Simulator: client connected from %s\n", conn.RemoteAddr())
+		go sim.serveConn(conn)
+	}
+}
+
+func (sim *Simulator) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	var cmd [4]byte
+	for {
+		if _, err := io.ReadFull(conn, cmd[:]); err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Printf("Simulator: read from %s failed: %v\n", conn.RemoteAddr(), err)
+			}
+			fmt.Printf("Simulator: client %s disconnected\n", conn.RemoteAddr())
+			return
+		}
+		reply, hasReply := sim.HandleCommand(cmd)
+		if !hasReply {
+			continue
+		}
+		if _, err := conn.Write(reply[:]); err != nil {
+			fmt.Printf("Simulator: write to %s failed: %v\n", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// floorSensor returns the floor the car is at, or -1 if it is between floors. Must be called with mu held.
+func (sim *Simulator) floorSensor() int {
+	nearest := math.Round(sim.position)
+	if math.Abs(sim.position-nearest) > floorSensorFraction/2 {
+		return -1
+	}
+	return int(nearest)
+}
+
+func (sim *Simulator) validButton(button int, floor int) bool {
+	return button >= 0 && button < NUM_BUTTON_TYPES && floor >= 0 && floor < sim.cfg.NumFloors
+}
+
+func toByte(a bool) byte {
+	if a {
+		return 1
+	}
+	return 0
+}
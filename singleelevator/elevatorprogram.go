@@ -5,6 +5,7 @@ import (
 	"elev/elevator"
 	"elev/elevator_fsm"
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -14,6 +15,9 @@ const (
 	HallButtonEvent                  ElevatorEventType = iota // Receives local hall button presses from node
 	LocalHallAssignmentCompleteEvent                          // Receives completed hall assignments
 	DoorStuckEvent                                            // Receives the elevator's door state (if it is stuck or not)
+	ServerConnectionEvent                                     // Receives whether the connection to the elevator server is lost or restored
+	EmergencyStopEvent                                        // Receives whether the emergency stop is engaged or released
+	MotorFaultEvent                                           // Receives whether the motor has failed to reach the next floor in time
 )
 
 type ElevatorOrderType int
@@ -22,13 +26,17 @@ const (
 	HallOrder ElevatorOrderType = iota
 	CabOrder
 	LightUpdate
+	ServiceModeOrder
 )
 
 // ElevatorEventMsg encapsulates all messages sent from elevator to node
 type ElevatorEvent struct {
-	EventType   ElevatorEventType
-	ButtonEvent elevator.ButtonEvent // For hall button events and completed hall assignments
-	DoorIsStuck bool                 // For door stuck status
+	EventType         ElevatorEventType
+	ButtonEvent       elevator.ButtonEvent // For hall button events and completed hall assignments
+	DoorIsStuck       bool                 // For door stuck status
+	ServerIsConnected bool                 // For elevator server connection status
+	IsStopped         bool                 // For emergency stop status
+	HasMotorFault     bool                 // For motor fault status
 }
 
 // NodeToElevatorMsg encapsulates all messages sent from node to elevator
@@ -37,41 +45,82 @@ type LightAndAssignmentUpdate struct {
 	HallAssignments [config.NUM_FLOORS][2]bool // For assigning hall calls to the elevator
 	CabAssignments  [config.NUM_FLOORS]bool    // For assigning cab calls to the elevator
 	LightStates     [config.NUM_FLOORS][2]bool // The new state of the lights
+	HomeFloor       int                        // For hall orders, the floor to park at when idle
+	// For hall orders, the destinations of the passengers to pick up at [floor][up/down]
+	Destinations [config.NUM_FLOORS][2][config.NUM_FLOORS]bool
+	ServiceMode  elevator.ServiceMode // For changing the service mode
+	ParkFloor    int                  // The floor to park at in the new service mode, -1 for none
 }
 
 // ElevatorProgram operates a single elevator
 // It manages the elevator state machine, hardware events,
 // and communicates with the node.
 func ElevatorProgram(
-	portNum string,
+	drv elevator.Driver,
+	strategy elevator.RequestStrategy,
 	elevatorEventTx chan<- ElevatorEvent,
 	elevLightAndAssignmentUpdateRx <-chan LightAndAssignmentUpdate,
 	elevatorStatesTx chan<- elevator.ElevatorState) {
 
-	elevator.Init(portNum, config.NUM_FLOORS) // "localhost:15657"
-	elevator_fsm.InitFSM()
+	ctrl := elevator_fsm.NewController(elevator_fsm.FSM{Strategy: strategy}, drv)
+	ctrl.Handle(elevator_fsm.Event{Type: elevator_fsm.InitEvent})
 
 	// Channels for events
 	buttonEventRx := make(chan elevator.ButtonEvent)
 	floorEventRx := make(chan int)
 	obstructionEventRx := make(chan bool)
+	serverConnectionRx := make(chan bool)
+	stopButtonRx := make(chan bool)
 
-	// Timers
-	doorStuckTimerActive := false
+	// Timers, the door timers are owned by the controller
+	motorWatchdogActive := false
+	hasMotorFault := false
+	doorIsStuck := false
 
-	doorOpenTimer := time.NewTimer(config.DOOR_OPEN_DURATION)   // 3-second timer to detect door timeout
-	doorStuckTimer := time.NewTimer(config.DOOR_STUCK_DURATION) // 30-second timer to detect stuck doors
-	doorOpenTimer.Stop()
-	doorStuckTimer.Stop()
+	// the faults are reported to the node with the elevator state
+	faults := &carFaults{}
+
+	motorWatchdogTimer := time.NewTimer(config.MOTOR_WATCHDOG_DURATION) // times each move between two floors
+	motorWatchdogTimer.Stop()
+
+	// Parking, the home floor is given by the master with the hall assignments
+	homeFloor := config.PARKING_HOME_FLOORS()[0]
+	idleTimerActive := false
+	idleTimer := time.NewTimer(config.PARKING_IDLE_TIMEOUT) // times how long the car has been idle
+	idleTimer.Stop()
+
+	// Destination dispatch, the destinations of the passengers we are to pick up are given with the hall assignments
+	var destinations [config.NUM_FLOORS][2][config.NUM_FLOORS]bool
+
+	// reports the requests cleared by the elevator to the node. The passengers picked up by a cleared hall request
+	// have entered their destination at the floor, it becomes a cab call
+	reportCleared := func(buttonEvent elevator.ButtonEvent) {
+		elevatorEventTx <- makeHallAssignmentCompleteEventMessage(buttonEvent)
+		if buttonEvent.Button == elevator.ButtonCab {
+			return
+		}
+		for destination, isDestination := range destinations[buttonEvent.Floor][buttonEvent.Button] {
+			if isDestination {
+				ctrl.Handle(makeRequestEvent(destination, elevator.ButtonCab))
+			}
+		}
+		destinations[buttonEvent.Floor][buttonEvent.Button] = [config.NUM_FLOORS]bool{}
+	}
 
 	// Start hardware monitoring routines
 	fmt.Println("Starting polling routines")
-	go elevator.PollButtons(buttonEventRx)
-	go elevator.PollFloorSensor(floorEventRx)
-	go elevator.PollObstructionSwitch(obstructionEventRx)
+	isConnected := drv.IsConnected()
+	go elevator.PollButtons(drv, buttonEventRx)
+	go elevator.PollFloorSensor(drv, floorEventRx)
+	go elevator.PollObstructionSwitch(drv, obstructionEventRx)
+	go elevator.PollServerConnection(drv, isConnected, serverConnectionRx)
+	go elevator.PollStopButton(drv, stopButtonRx)
 
 	// Transmits the elevator state to the node periodically
-	go transmitElevatorState(elevatorStatesTx)
+	go transmitElevatorState(ctrl, faults, elevatorStatesTx)
+
+	// the driver may still be waiting for the server to come up
+	elevatorEventTx <- makeServerConnectionMessage(isConnected)
 
 	// Check if door is stuck
 	elevatorEventTx <- makeDoorStuckMessage(false)
@@ -79,8 +128,14 @@ func ElevatorProgram(
 	for {
 		select {
 		case button := <-buttonEventRx:
+			if ctrl.Elevator().ServiceMode == elevator.RecallService {
+				// calls are ignored until the fire recall is reset
+				break
+			}
 			if button.Button == elevator.ButtonCab { // Handle cab calls internally
-				elevator_fsm.OnRequestButtonPress(button.Floor, button.Button, doorOpenTimer)
+				for _, buttonEvent := range ctrl.Handle(makeRequestEvent(button.Floor, button.Button)) {
+					reportCleared(buttonEvent)
+				}
 			} else {
 				elevatorEventTx <- makeHallButtonEventMessage(button)
 			}
@@ -88,17 +143,19 @@ func ElevatorProgram(
 		case msg := <-elevLightAndAssignmentUpdateRx:
 			switch msg.OrderType {
 			case HallOrder:
+				homeFloor = msg.HomeFloor
+				destinations = msg.Destinations
 				for floor := 0; floor < config.NUM_FLOORS; floor++ {
 					for hallButton := 0; hallButton < 2; hallButton++ {
 						if msg.HallAssignments[floor][hallButton] { // If the elevator is idle and the button is pressed in the same floor, the door should remain open
-							clearedEvents := elevator_fsm.OnRequestButtonPress(floor, elevator.ButtonType(hallButton), doorOpenTimer)
+							clearedEvents := ctrl.Handle(makeRequestEvent(floor, elevator.ButtonType(hallButton)))
 							for _, buttonEvent := range clearedEvents {
-								if buttonEvent.Button != elevator.ButtonCab && buttonEvent.Floor == floor {
-									elevatorEventTx <- makeHallAssignmentCompleteEventMessage(buttonEvent)
+								if buttonEvent.Floor == floor {
+									reportCleared(buttonEvent)
 								}
 							}
-						} else if !msg.HallAssignments[floor][hallButton] && elevator_fsm.GetElevator().Requests[floor][hallButton] {
-							elevator_fsm.RemoveRequest(floor, elevator.ButtonType(hallButton))
+						} else if !msg.HallAssignments[floor][hallButton] && ctrl.Elevator().Requests[floor][hallButton] {
+							ctrl.Handle(elevator_fsm.Event{Type: elevator_fsm.RequestRemovedEvent, Floor: floor, Button: elevator.ButtonType(hallButton)})
 						}
 
 					}
@@ -106,57 +163,157 @@ func ElevatorProgram(
 			case CabOrder:
 				for floor := 0; floor < config.NUM_FLOORS; floor++ {
 					if msg.CabAssignments[floor] {
-						elevator_fsm.OnRequestButtonPress(floor, elevator.ButtonCab, doorOpenTimer)
+						for _, buttonEvent := range ctrl.Handle(makeRequestEvent(floor, elevator.ButtonCab)) {
+							reportCleared(buttonEvent)
+						}
 					}
 				}
 			case LightUpdate:
-				elevator_fsm.SetHallLights(msg.LightStates)
+				ctrl.Handle(elevator_fsm.Event{Type: elevator_fsm.HallLightsEvent, HallLightStates: msg.LightStates})
+			case ServiceModeOrder:
+				fmt.Printf("Service mode: %s\n", msg.ServiceMode.String())
+				ctrl.Handle(elevator_fsm.Event{Type: elevator_fsm.ServiceModeEvent, ServiceMode: msg.ServiceMode, Floor: msg.ParkFloor})
 			}
 
 		case floor := <-floorEventRx:
-			clearedButtonEvents := elevator_fsm.OnFloorArrival(floor, doorOpenTimer)
+			// a floor arrival proves the motor works, restart the timing of the next move
+			motorWatchdogTimer.Stop()
+			motorWatchdogActive = false
+			if hasMotorFault {
+				hasMotorFault = false
+				faults.setMotorFault(false)
+				elevatorEventTx <- makeMotorFaultMessage(false)
+			}
+
+			clearedButtonEvents := ctrl.Handle(elevator_fsm.Event{Type: elevator_fsm.FloorArrivalEvent, Floor: floor})
 
 			// loop through and send the button events!
 			for _, buttonEvent := range clearedButtonEvents {
 				fmt.Printf("Button event: %v\n", buttonEvent)
-				if buttonEvent.Button != elevator.ButtonCab {
-					elevatorEventTx <- makeHallAssignmentCompleteEventMessage(buttonEvent)
-				}
+				reportCleared(buttonEvent)
 			}
 
 		case isObstructed := <-obstructionEventRx:
-			elevator_fsm.SetObstruction(isObstructed)
+			ctrl.Handle(elevator_fsm.Event{Type: elevator_fsm.ObstructionEvent, IsObstructed: isObstructed})
 
-		case <-doorOpenTimer.C:
-			// Start the door stuck timer, which is stopped by OnDoorTimeout if the doors are able to close
-
-			if !doorStuckTimerActive {
-				doorStuckTimer.Reset(config.DOOR_STUCK_DURATION)
-				doorStuckTimerActive = true
+		case <-ctrl.DoorOpenTimer.C:
+			// the door stuck timer runs from the door opens until it closes
+			for _, buttonEvent := range ctrl.Handle(elevator_fsm.Event{Type: elevator_fsm.DoorTimeoutEvent}) {
+				reportCleared(buttonEvent)
 			}
-			elevator_fsm.OnDoorTimeout(doorOpenTimer, doorStuckTimer)
 
-		case <-doorStuckTimer.C:
+		case <-ctrl.DoorStuckTimer.C:
+			doorIsStuck = true
+			faults.setDoorStuck(true)
 			elevatorEventTx <- makeDoorStuckMessage(true)
+
+		case <-motorWatchdogTimer.C:
+			// the watchdog is not rearmed until the next floor arrival, which also clears the fault
+			fmt.Println("Motor watchdog timed out, the next floor was not reached in time")
+			hasMotorFault = true
+			faults.setMotorFault(true)
+			elevatorEventTx <- makeMotorFaultMessage(true)
+
+		case <-idleTimer.C:
+			// idleTimerActive stays set, so the car is not parked again until it has been busy
+			ctrl.Handle(elevator_fsm.Event{Type: elevator_fsm.ParkEvent, Floor: homeFloor})
+
+		case isPressed := <-stopButtonRx:
+			// the emergency stop toggles on every press, releasing the button does nothing
+			if isPressed {
+				ctrl.Handle(elevator_fsm.Event{Type: elevator_fsm.StopButtonEvent, Floor: drv.GetFloor()})
+				elevatorEventTx <- makeEmergencyStopMessage(ctrl.Elevator().IsStopped)
+			}
+
+		case isConnected := <-serverConnectionRx:
+			if isConnected {
+				// the driver has restored the lamps, make sure the elevator finds a floor again
+				ctrl.Handle(elevator_fsm.Event{Type: elevator_fsm.ServerReconnectEvent, Floor: drv.GetFloor()})
+			}
+			elevatorEventTx <- makeServerConnectionMessage(isConnected)
+		}
+
+		// the door is no longer stuck once it has closed
+		if doorIsStuck && ctrl.Elevator().Behavior != elevator.DoorOpen {
+			doorIsStuck = false
+			faults.setDoorStuck(false)
+			elevatorEventTx <- makeDoorStuckMessage(false)
 		}
+
+		// time every move between floors, and stop timing as soon as the car stands still
+		isMoving := ctrl.Elevator().Behavior == elevator.Moving
+		if isMoving && !motorWatchdogActive && !hasMotorFault {
+			motorWatchdogTimer.Reset(config.MOTOR_WATCHDOG_DURATION)
+			motorWatchdogActive = true
+		} else if !isMoving && motorWatchdogActive {
+			motorWatchdogTimer.Stop()
+			motorWatchdogActive = false
+		}
+
+		// time how long the car has stood idle without requests
+		elev := ctrl.Elevator()
+		isIdle := elev.Behavior == elevator.Idle && !elevator.RequestsAny(elev)
+		if isIdle && !idleTimerActive {
+			idleTimer.Reset(config.PARKING_IDLE_TIMEOUT)
+			idleTimerActive = true
+		} else if !isIdle && idleTimerActive {
+			idleTimer.Stop()
+			idleTimerActive = false
+		}
+	}
+}
+
+// carFaults holds the faults detected by the elevator program, shared with the state transmitter
+type carFaults struct {
+	mu         sync.Mutex
+	doorStuck  bool
+	motorFault bool
+}
+
+func (faults *carFaults) setDoorStuck(isStuck bool) {
+	faults.mu.Lock()
+	defer faults.mu.Unlock()
+	faults.doorStuck = isStuck
+}
+
+func (faults *carFaults) setMotorFault(hasFault bool) {
+	faults.mu.Lock()
+	defer faults.mu.Unlock()
+	faults.motorFault = hasFault
+}
+
+// health returns the health of the car with the given state
+func (faults *carFaults) health(elev elevator.Elevator) elevator.ElevatorHealth {
+	faults.mu.Lock()
+	defer faults.mu.Unlock()
+	return elevator.ElevatorHealth{
+		Obstructed:  elev.IsObstructed,
+		DoorStuck:   faults.doorStuck,
+		MotorFault:  faults.motorFault,
+		Maintenance: elev.ServiceMode == elevator.MaintenanceService,
 	}
 }
 
-func transmitElevatorState(elevatorToNode chan<- elevator.ElevatorState) {
+func transmitElevatorState(ctrl *elevator_fsm.Controller, faults *carFaults, elevatorToNode chan<- elevator.ElevatorState) {
 
 	for range time.Tick(config.ELEV_STATE_TRANSMIT_INTERVAL) {
-		// call getelevator
-		elev := elevator_fsm.GetElevator()
+		elev := ctrl.Elevator()
 
 		elevatorToNode <- elevator.ElevatorState{
 			Behavior:    elev.Behavior,
 			Floor:       elev.Floor,
 			Direction:   elev.Dir,
 			CabRequests: elevator.GetCabRequestsAsElevState(elev),
+			ServiceMode: elev.ServiceMode,
+			Health:      faults.health(elev),
 		}
 	}
 }
 
+func makeRequestEvent(floor int, button elevator.ButtonType) elevator_fsm.Event {
+	return elevator_fsm.Event{Type: elevator_fsm.RequestEvent, Floor: floor, Button: button}
+}
+
 func makeHallButtonEventMessage(buttonEvent elevator.ButtonEvent) ElevatorEvent {
 	return ElevatorEvent{EventType: HallButtonEvent,
 		ButtonEvent: buttonEvent, DoorIsStuck: false}
@@ -172,3 +329,21 @@ func makeDoorStuckMessage(isDoorStuck bool) ElevatorEvent {
 		DoorIsStuck: isDoorStuck,
 	}
 }
+
+func makeServerConnectionMessage(isConnected bool) ElevatorEvent {
+	return ElevatorEvent{EventType: ServerConnectionEvent,
+		ServerIsConnected: isConnected,
+	}
+}
+
+func makeEmergencyStopMessage(isStopped bool) ElevatorEvent {
+	return ElevatorEvent{EventType: EmergencyStopEvent,
+		IsStopped: isStopped,
+	}
+}
+
+func makeMotorFaultMessage(hasMotorFault bool) ElevatorEvent {
+	return ElevatorEvent{EventType: MotorFaultEvent,
+		HasMotorFault: hasMotorFault,
+	}
+}
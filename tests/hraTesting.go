@@ -32,6 +32,6 @@ package tests
 // 	allElevStates[0] = newMessage1
 // 	allElevStates[1] = newMessage2
 
-// 	output := hallRequestAssigner.HRAalgorithm(allElevStates, GlobalHallRequest)
+// 	output := hallRequestAssigner.HRAassign(hallRequestAssigner.MakeHRAInput(allElevStates, GlobalHallRequest))
 // 	fmt.Printf("Output: %v\n", output)
 // }
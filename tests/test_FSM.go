@@ -1,11 +1,12 @@
 package tests
 
 import (
+	"elev/elevator"
 	"elev/node"
 )
 
 func RunTestNode() {
-	Node1 := node.MakeNode(1)
+	Node1 := node.MakeNode(1, elevator.NewMemoryDriver(), 20011, 20011)
 	go node.SlaveProgram(Node1)
 
 	// Node1.NodeElevStatesTx <- messages.ElevStates{NodeID: 1, Direction: elevator.DirectionUp, Behavior: "idle", Floor: 1, CabRequest: [4]bool{false, true, false, false}}
@@ -0,0 +1,393 @@
+// Package trafficsim runs elevator cars against synthetic passenger traffic in virtual time, so that dispatch
+// policies and timing parameters can be compared without hardware or waiting for the wall clock.
+//
+// Each car runs the real state machine of elevator_fsm. The hall requests are distributed by a hall assigner the
+// same way the master does: every time a new hall request appears. Passengers board the car that clears the hall
+// request of their direction at their floor, press the cab button of their destination, and leave when the car
+// opens its door there. Parking and faults are not simulated.
+package trafficsim
+
+import (
+	"container/heap"
+	"elev/config"
+	"elev/costFNS/assigner"
+	"elev/elevator"
+	"elev/elevator_fsm"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Traffic pattern names
+const (
+	UpPeak     = "uppeak"     // most passengers travel from the lobby to the floors above
+	DownPeak   = "downpeak"   // most passengers travel from the floors above to the lobby
+	InterFloor = "interfloor" // passengers travel between the floors above the lobby
+	Random     = "random"     // every origin and destination is equally likely
+)
+
+// Patterns lists the traffic patterns
+var Patterns = []string{UpPeak, DownPeak, InterFloor, Random}
+
+type Config struct {
+	Pattern          string
+	NumElevators     int
+	ArrivalRate      float64       // passengers per minute
+	Duration         time.Duration // how long passengers keep arriving, the cars then finish their requests
+	TravelDuration   time.Duration // time it takes to travel from one floor to the next
+	DoorOpenDuration time.Duration // how long the door stays open at a stop
+	Seed             int64
+	Strategy         elevator.RequestStrategy // how each car services its requests, collective control if nil
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Pattern:          Random,
+		NumElevators:     3,
+		ArrivalRate:      10,
+		Duration:         time.Hour,
+		TravelDuration:   config.HRA_TRAVEL_DURATION,
+		DoorOpenDuration: config.DOOR_OPEN_DURATION,
+		Seed:             1,
+	}
+}
+
+// maxDrainDuration bounds how long the cars get to deliver the last passengers after the arrivals stop
+const maxDrainDuration = time.Hour
+
+// Result is the outcome of a simulation
+type Result struct {
+	Passengers int // passengers that arrived
+	Delivered  int // passengers that reached their destination
+	WaitTimes  []time.Duration
+	RideTimes  []time.Duration
+	Stops      int // door openings of all cars
+}
+
+// Average returns the mean of the durations, 0 if there are none
+func Average(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+	return sum / time.Duration(len(durations))
+}
+
+// Percentile returns the p-th percentile of the durations by the nearest rank method, 0 if there are none
+func Percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+type passenger struct {
+	origin      int
+	destination int
+	arrived     time.Duration
+	boarded     time.Duration
+}
+
+func (p passenger) button() elevator.ButtonType {
+	if p.destination > p.origin {
+		return elevator.ButtonHallUp
+	}
+	return elevator.ButtonHallDown
+}
+
+type car struct {
+	elev       elevator.Elevator
+	moveGen    int  // bumped to cancel the scheduled floor arrival
+	arriving   bool // a floor arrival is scheduled
+	doorGen    int  // bumped to cancel the scheduled door timeout
+	passengers []passenger
+}
+
+type eventType int
+
+const (
+	arrivalEvent eventType = iota
+	floorArrivalEvent
+	doorTimeoutEvent
+)
+
+type event struct {
+	at        time.Duration
+	seq       int // keeps events at the same time in the order they were scheduled
+	typ       eventType
+	car       int
+	gen       int
+	passenger passenger
+}
+
+type eventQueue []event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+func (q eventQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(event)) }
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+type simulation struct {
+	cfg          Config
+	fsm          elevator_fsm.FSM
+	hallAssigner assigner.Assigner
+	rng          *rand.Rand
+
+	now          time.Duration
+	seq          int
+	queue        eventQueue
+	cars         []*car
+	waiting      [config.NUM_FLOORS][]passenger
+	hallRequests [config.NUM_FLOORS][2]bool
+	result       Result
+}
+
+// Run simulates the traffic with the hall requests distributed by hallAssigner
+func Run(cfg Config, hallAssigner assigner.Assigner) (Result, error) {
+	if cfg.NumElevators < 1 {
+		return Result{}, fmt.Errorf("need at least one elevator, got %d", cfg.NumElevators)
+	}
+	if cfg.ArrivalRate <= 0 {
+		return Result{}, fmt.Errorf("arrival rate must be positive, got %v", cfg.ArrivalRate)
+	}
+	if !validPattern(cfg.Pattern) {
+		return Result{}, fmt.Errorf("unknown traffic pattern %q", cfg.Pattern)
+	}
+
+	sim := &simulation{
+		cfg:          cfg,
+		fsm:          elevator_fsm.FSM{Strategy: cfg.Strategy},
+		hallAssigner: hallAssigner,
+		rng:          rand.New(rand.NewSource(cfg.Seed)),
+	}
+	for i := 0; i < cfg.NumElevators; i++ {
+		// the cars start idle at the lobby
+		e := elevator.NewElevator()
+		e.Floor = 0
+		sim.cars = append(sim.cars, &car{elev: e})
+	}
+
+	sim.scheduleArrival()
+	for sim.queue.Len() > 0 {
+		ev := heap.Pop(&sim.queue).(event)
+		if ev.at > cfg.Duration+maxDrainDuration {
+			break
+		}
+		sim.now = ev.at
+
+		switch ev.typ {
+		case arrivalEvent:
+			sim.onArrival(ev.passenger)
+			sim.scheduleArrival()
+		case floorArrivalEvent:
+			c := sim.cars[ev.car]
+			if ev.gen == c.moveGen {
+				c.arriving = false
+				sim.handle(ev.car, elevator_fsm.Event{Type: elevator_fsm.FloorArrivalEvent, Floor: c.elev.Floor + int(c.elev.Dir)})
+			}
+		case doorTimeoutEvent:
+			if ev.gen == sim.cars[ev.car].doorGen {
+				sim.handle(ev.car, elevator_fsm.Event{Type: elevator_fsm.DoorTimeoutEvent})
+			}
+		}
+	}
+	return sim.result, nil
+}
+
+func validPattern(pattern string) bool {
+	for _, p := range Patterns {
+		if p == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func (sim *simulation) schedule(ev event) {
+	ev.seq = sim.seq
+	sim.seq++
+	heap.Push(&sim.queue, ev)
+}
+
+// scheduleArrival schedules the next passenger, arrivals are a Poisson process
+func (sim *simulation) scheduleArrival() {
+	interval := time.Duration(sim.rng.ExpFloat64() / sim.cfg.ArrivalRate * float64(time.Minute))
+	at := sim.now + interval
+	if at > sim.cfg.Duration {
+		return
+	}
+	origin, destination := sim.trip()
+	sim.schedule(event{at: at, typ: arrivalEvent, passenger: passenger{origin: origin, destination: destination, arrived: at}})
+}
+
+// trip draws the origin and destination of a passenger from the traffic pattern
+func (sim *simulation) trip() (int, int) {
+	const lobby = 0
+	upper := func() int { return 1 + sim.rng.Intn(config.NUM_FLOORS-1) }
+	anyFloorBut := func(floor int) int {
+		other := sim.rng.Intn(config.NUM_FLOORS - 1)
+		if other >= floor {
+			other++
+		}
+		return other
+	}
+
+	// peak traffic is mostly to or from the lobby, with some random trips mixed in
+	peakShare := 0.8
+	switch pattern := sim.cfg.Pattern; {
+	case pattern == UpPeak && sim.rng.Float64() < peakShare:
+		return lobby, upper()
+	case pattern == DownPeak && sim.rng.Float64() < peakShare:
+		return upper(), lobby
+	case pattern == InterFloor && config.NUM_FLOORS > 2:
+		origin := upper()
+		destination := anyFloorBut(origin)
+		for destination == lobby {
+			destination = anyFloorBut(origin)
+		}
+		return origin, destination
+	default:
+		origin := sim.rng.Intn(config.NUM_FLOORS)
+		return origin, anyFloorBut(origin)
+	}
+}
+
+func (sim *simulation) onArrival(p passenger) {
+	sim.result.Passengers++
+	sim.waiting[p.origin] = append(sim.waiting[p.origin], p)
+	btn := p.button()
+	if sim.hallRequests[p.origin][btn] {
+		return
+	}
+	// a new hall request, the master distributes the hall requests again
+	sim.hallRequests[p.origin][btn] = true
+	sim.assign()
+}
+
+// assign distributes the hall requests over the cars, and updates the requests of the cars like the elevator program does
+func (sim *simulation) assign() {
+	elevStates := make(map[int]elevator.ElevatorState, len(sim.cars))
+	for id, c := range sim.cars {
+		elevStates[id] = elevator.ElevatorState{
+			Floor:       c.elev.Floor,
+			Direction:   c.elev.Dir,
+			Behavior:    c.elev.Behavior,
+			CabRequests: elevator.GetCabRequestsAsElevState(c.elev),
+		}
+	}
+	assignments, err := sim.hallAssigner.Assign(elevStates, sim.hallRequests)
+	if err != nil {
+		// the cars keep what they have, the requests are distributed on the next new request
+		return
+	}
+
+	for id, c := range sim.cars {
+		for floor := 0; floor < config.NUM_FLOORS; floor++ {
+			for btn := elevator.ButtonType(0); btn < 2; btn++ {
+				if assignments[id][floor][btn] {
+					sim.handle(id, elevator_fsm.Event{Type: elevator_fsm.RequestEvent, Floor: floor, Button: btn})
+				} else if c.elev.Requests[floor][btn] {
+					sim.handle(id, elevator_fsm.Event{Type: elevator_fsm.RequestRemovedEvent, Floor: floor, Button: btn})
+				}
+			}
+		}
+	}
+}
+
+// handle runs a transition of a car and carries out its actions in virtual time
+func (sim *simulation) handle(id int, ev elevator_fsm.Event) {
+	c := sim.cars[id]
+	var actions []elevator_fsm.Action
+	c.elev, actions = sim.fsm.Transition(c.elev, ev)
+
+	var cleared []elevator.ButtonEvent
+	for _, action := range actions {
+		switch action.Type {
+		case elevator_fsm.SetMotorDirection:
+			c.moveGen++
+			c.arriving = false
+		case elevator_fsm.SetDoorOpenLamp:
+			if action.Value {
+				sim.result.Stops++
+			}
+		case elevator_fsm.StartDoorTimer:
+			c.doorGen++
+			sim.schedule(event{at: sim.now + sim.cfg.DoorOpenDuration, typ: doorTimeoutEvent, car: id, gen: c.doorGen})
+		case elevator_fsm.StopDoorTimer:
+			c.doorGen++
+		case elevator_fsm.HallRequestCleared:
+			cleared = append(cleared, elevator.ButtonEvent{Floor: action.Floor, Button: action.Button})
+		}
+	}
+
+	// a moving car reaches the next floor, also when it passes a floor without stopping
+	if c.elev.Behavior == elevator.Moving && !c.arriving {
+		c.arriving = true
+		sim.schedule(event{at: sim.now + sim.cfg.TravelDuration, typ: floorArrivalEvent, car: id, gen: c.moveGen})
+	}
+	if c.elev.Behavior == elevator.DoorOpen {
+		sim.alight(c)
+	}
+	for _, buttonEvent := range cleared {
+		sim.hallRequests[buttonEvent.Floor][buttonEvent.Button] = false
+		sim.board(id, buttonEvent)
+	}
+}
+
+// alight lets the passengers out at their destination
+func (sim *simulation) alight(c *car) {
+	remaining := c.passengers[:0]
+	for _, p := range c.passengers {
+		if p.destination == c.elev.Floor {
+			sim.result.Delivered++
+			sim.result.RideTimes = append(sim.result.RideTimes, sim.now-p.boarded)
+		} else {
+			remaining = append(remaining, p)
+		}
+	}
+	c.passengers = remaining
+}
+
+// board lets the passengers waiting for the cleared hall request into the car, they press their destination
+func (sim *simulation) board(id int, served elevator.ButtonEvent) {
+	c := sim.cars[id]
+	var stillWaiting []passenger
+	var boarding []passenger
+	for _, p := range sim.waiting[served.Floor] {
+		if p.button() == served.Button {
+			p.boarded = sim.now
+			sim.result.WaitTimes = append(sim.result.WaitTimes, sim.now-p.arrived)
+			boarding = append(boarding, p)
+		} else {
+			stillWaiting = append(stillWaiting, p)
+		}
+	}
+	sim.waiting[served.Floor] = stillWaiting
+	c.passengers = append(c.passengers, boarding...)
+
+	for _, p := range boarding {
+		sim.handle(id, elevator_fsm.Event{Type: elevator_fsm.RequestEvent, Floor: p.destination, Button: elevator.ButtonCab})
+	}
+}